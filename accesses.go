@@ -0,0 +1,131 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"io"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+)
+
+// decodeAccesses expands the run-length encoded accesses of a program point
+// into one access count per byte offset of its blocks. A negative element -n
+// means that the element which follows it is repeated n times.
+func decodeAccesses(acc []int) []int {
+	counts := make([]int, 0, len(acc))
+	for i := 0; i < len(acc); i++ {
+		if acc[i] >= 0 {
+			counts = append(counts, acc[i])
+			continue
+		}
+		if i+1 == len(acc) {
+			break
+		}
+		for n := -acc[i]; n > 0; n-- {
+			counts = append(counts, acc[i+1])
+		}
+		i++
+	}
+	return counts
+}
+
+// heatmapRowLen is the number of byte offsets shown on each heatmap row.
+const heatmapRowLen = 16
+
+// printHeatmap writes the access counts as a HTML table with one cell for
+// each byte offset, the most accessed offsets having the darkest cells. The
+// rows are the fields of the layout or, without one, every heatmapRowLen
+// offsets.
+func printHeatmap(w io.Writer, counts []int, layout []structField) {
+	maxCount := 0
+	for _, c := range counts {
+		maxCount = max(maxCount, c)
+	}
+
+	if layout == nil {
+		for row := 0; row < len(counts); row += heatmapRowLen {
+			layout = append(layout, structField{start: row, end: min(row+heatmapRowLen, len(counts)) - 1})
+		}
+	}
+
+	fmt.Fprintln(w, `<table class="heatmap">`)
+	for _, f := range layout {
+		header := strconv.Itoa(f.start)
+		if f.name != "" {
+			header = html.EscapeString(f.name)
+		}
+		fmt.Fprintf(w, "<tr><th>%s</th>", header)
+		for off := f.start; off <= f.end && off < len(counts); off++ {
+			alpha := 0.0
+			if maxCount > 0 {
+				alpha = float64(counts[off]) / float64(maxCount)
+			}
+			fmt.Fprintf(
+				w, `<td style="background-color: rgba(200, 0, 0, %.2f)" title="offset %d: %d accesses"></td>`,
+				alpha, off, counts[off],
+			)
+		}
+		fmt.Fprintln(w, "</tr>")
+	}
+	fmt.Fprintln(w, "</table>")
+}
+
+// frameAccesses is what -hot-access-frames shows for one symbol.
+type frameAccesses struct {
+	sym           int
+	reads, writes int
+
+	// The number of program points with the symbol in their stack.
+	sites int
+}
+
+// hotAccessFrames returns the symbols of the frames of the program points pps
+// with the reads and writes of the blocks allocated under them, the most
+// accessed first. Like frameStats, each program point is counted once for a
+// symbol, even if it appears many times in its stack.
+func hotAccessFrames(r *Report, pps []int, opts *options) []frameAccesses {
+	index := make(map[int]int)
+	var frames []frameAccesses
+	last := make(map[int]int)
+	for _, i := range pps {
+		pp := r.ProgramPoints[i]
+		for _, frame := range opts.visibleFrames(r, pp) {
+			sym := r.frameSym[frame]
+			if n, ok := last[sym]; ok && n == i {
+				continue
+			}
+			last[sym] = i
+
+			n, ok := index[sym]
+			if !ok {
+				n = len(frames)
+				index[sym] = n
+				frames = append(frames, frameAccesses{sym: sym})
+			}
+			frames[n].reads += pp.ReadsOfBlocks
+			frames[n].writes += pp.WritesOfBlocks
+			frames[n].sites++
+		}
+	}
+
+	slices.SortStableFunc(frames, func(a, b frameAccesses) int {
+		return cmp.Compare(b.reads+b.writes, a.reads+a.writes)
+	})
+	return frames
+}
+
+// printHotAccessFrames writes the frames, one per line, with their accesses.
+func printHotAccessFrames(w io.Writer, r *Report, frames []frameAccesses, opts *options) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCESSES\tREADS\tWRITES\tSITES\tFRAME")
+	for _, f := range frames {
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%d\t%s\n",
+			opts.count(f.reads+f.writes), opts.count(f.reads), opts.count(f.writes), f.sites,
+			opts.displayFrame(r.Symbol(f.sym)),
+		)
+	}
+	tw.Flush()
+}
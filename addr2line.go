@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// unresolvedAddress returns the address of the frame table entry if DHAT
+// didn't resolve its symbol: it is missing, empty, "???" or an address too.
+func unresolvedAddress(frame string) (string, bool) {
+	addr, sym, found := strings.Cut(frame, ": ")
+	if found && sym != "???" && sym != "" && !isAddress(sym) {
+		return "", false
+	}
+	if !isAddress(addr) {
+		return "", false
+	}
+	return addr, true
+}
+
+// isAddress reports whether s is a hexadecimal address, like "0x401136".
+func isAddress(s string) bool {
+	digits, ok := strings.CutPrefix(s, "0x")
+	if !ok || digits == "" {
+		return false
+	}
+	for _, c := range digits {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// printUnresolved writes the addresses of the frames of r left unresolved,
+// also by -binary, one per line and each once, e.g. to give them to addr2line.
+func printUnresolved(w io.Writer, r *Report) {
+	seen := make(map[string]bool)
+	for _, frame := range r.FramesTable {
+		addr, ok := unresolvedAddress(frame)
+		if !ok || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		fmt.Fprintln(w, addr)
+	}
+}
+
+// resolveWithBinary resolves the frames of r which are only addresses using
+// addr2line on the binary given with -binary. The results are cached, so the
+// same address is never looked up twice, also across the baselines.
+func (o *options) resolveWithBinary(r *Report) error {
+	if o.addrCache == nil {
+		o.addrCache = make(map[string]string)
+	}
+
+	var lookup []string
+	for _, frame := range r.FramesTable {
+		addr, ok := unresolvedAddress(frame)
+		if _, cached := o.addrCache[addr]; ok && !cached {
+			o.addrCache[addr] = ""
+			lookup = append(lookup, addr)
+		}
+	}
+
+	if len(lookup) != 0 {
+		symbols, err := addr2line(o.binary, lookup)
+		if err != nil {
+			return err
+		}
+		for n, addr := range lookup {
+			o.addrCache[addr] = symbols[n]
+		}
+	}
+
+	changed := false
+	for i, frame := range r.FramesTable {
+		addr, ok := unresolvedAddress(frame)
+		if !ok || o.addrCache[addr] == "" {
+			continue
+		}
+		r.FramesTable[i] = addr + ": " + o.addrCache[addr]
+		changed = true
+	}
+	if changed {
+		r.resolveFrames()
+	}
+	return nil
+}
+
+// addr2line returns the symbols of the addresses in binary, formatted like
+// the ones resolved by DHAT, or "" for the ones addr2line doesn't know either.
+// The addresses are given on the standard input of addr2line, as there can be
+// too many of them for its arguments.
+func addr2line(binary string, addrs []string) ([]string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("addr2line", "-e", binary, "-f", "-C")
+	cmd.Stdin = strings.NewReader(strings.Join(addrs, "\n") + "\n")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("addr2line: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// addr2line prints two lines for each address: the function and its
+	// file:line, with ?? for the unknown parts.
+	symbols := make([]string, 0, len(addrs))
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for len(symbols) < len(addrs) {
+		if !sc.Scan() {
+			return nil, fmt.Errorf("addr2line: expected %d symbols, got %d", len(addrs), len(symbols))
+		}
+		function := sc.Text()
+		if !sc.Scan() {
+			return nil, fmt.Errorf("addr2line: missing location of %s", addrs[len(symbols)])
+		}
+		location := sc.Text()
+		if before, _, found := strings.Cut(location, " (discriminator"); found {
+			location = before
+		}
+
+		switch {
+		case function == "??":
+			symbols = append(symbols, "")
+		case strings.HasPrefix(location, "??"):
+			symbols = append(symbols, function)
+		default:
+			symbols = append(symbols, function+" ("+location+")")
+		}
+	}
+	return symbols, sc.Err()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// parseAnnotateFile reads the notes given with -annotate for allocation sites.
+// Each line has the stable id of a site, as printed by -stable-ids, followed
+// by the note. Empty lines and comment lines are ignored, as in the ignore
+// file. A missing file means no notes.
+func parseAnnotateFile(file string) (map[string]string, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	notes := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		line := strings.Trim(line, " \t")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		id, note, _ := strings.Cut(line, " ")
+		if note = strings.TrimSpace(note); note != "" {
+			notes[id] = note
+		}
+	}
+	return notes, nil
+}
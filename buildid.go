@@ -0,0 +1,89 @@
+package main
+
+import (
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// ntGNUBuildID is the type of the ELF note holding the build-id.
+const ntGNUBuildID = 3
+
+// elfBuildID returns the GNU build-id of the ELF file, or "" if it has none.
+func elfBuildID(file string) (string, error) {
+	f, err := elf.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sec := f.Section(".note.gnu.build-id")
+	if sec == nil {
+		return "", nil
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return "", err
+	}
+
+	// A note is the sizes of its name and description, its type, then the
+	// name and the description, each padded to 4 bytes.
+	for len(data) >= 12 {
+		nameSize := int(f.ByteOrder.Uint32(data[0:]))
+		descSize := int(f.ByteOrder.Uint32(data[4:]))
+		typ := f.ByteOrder.Uint32(data[8:])
+		data = data[12:]
+
+		nameEnd := (nameSize + 3) &^ 3
+		descEnd := nameEnd + ((descSize + 3) &^ 3)
+		if descEnd > len(data) {
+			break
+		}
+		if typ == ntGNUBuildID && strings.TrimRight(string(data[:nameSize]), "\x00") == "GNU" {
+			return hex.EncodeToString(data[nameEnd : nameEnd+descSize]), nil
+		}
+		data = data[descEnd:]
+	}
+	return "", nil
+}
+
+// checkBuildID warns on w if the binary given with -binary isn't the
+// profiled one: its build-id must be the one given with -build-id or, without
+// it, the one of the program in the command of r, if that is still around.
+// Nothing is checked if either of them has no build-id.
+func (o *options) checkBuildID(w io.Writer, r *Report) {
+	got, err := elfBuildID(o.binary)
+	if err != nil {
+		fmt.Fprintf(w, "warning: cannot read the build ID of %s, so -binary is not verified: %v\n", o.binary, err)
+		return
+	}
+
+	want, from := o.buildID, "-build-id"
+	if want == "" {
+		program, _ := splitCommand(r.Cmd)
+		if program == "" || program == o.binary {
+			return
+		}
+		want, err = elfBuildID(program)
+		if errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(w, "warning: cannot read the build ID of %s, so -binary is not verified: %v\n", program, err)
+			return
+		}
+		from = program
+	}
+
+	if want != "" && got != "" && !strings.EqualFold(want, got) {
+		fmt.Fprintf(
+			w, "warning: build ID of %s does not match the profile: it is %s, expected %s from %s, "+
+				"the symbols resolved with -binary are probably wrong\n",
+			o.binary, got, want, from,
+		)
+	}
+}
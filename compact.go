@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// compactLeafLen is the length to which the leaf frames are truncated by
+// printCompact.
+const compactLeafLen = 80
+
+// printCompact writes the header of the report followed by one line for each
+// of the program points pps, showing only their leaf frame, and the notes
+// about how they were selected.
+func printCompact(w io.Writer, report *Report, pps []int, notes []string, opts *options) {
+	printHeader(w, report, opts)
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "#\t%s\t%s\tLEAF\n", strings.ToUpper(report.BytesLabel()), strings.ToUpper(report.BlocksLabel()))
+	for n, i := range pps {
+		pp := report.ProgramPoints[i]
+		leaf := ""
+		if frames := opts.visibleFrames(report, pp); len(frames) != 0 {
+			leaf = truncateFrame(opts.displayFrame(report.GetFrame(frames[0])), compactLeafLen)
+		}
+		fmt.Fprintf(
+			tw, "#%d\t%s\t%s\t%s\n",
+			opts.allocNumber(n+1, i), opts.bytes(pp.TotalBytes), opts.count(pp.TotalBlocks), leaf,
+		)
+	}
+	tw.Flush()
+
+	if len(notes) != 0 {
+		fmt.Fprintln(w)
+	}
+	for _, note := range notes {
+		fmt.Fprintln(w, note)
+	}
+}
+
+// printHTMLFragment writes a HTML table with one row for each of the program
+// points pps, with the same columns as printCompact, to be embedded in other
+// pages. Unlike the -html output it is not a whole document.
+func printHTMLFragment(w io.Writer, report *Report, pps []int, opts *options) {
+	fmt.Fprintln(w, `<table class="dhatless-allocations">`)
+	fmt.Fprintf(
+		w, "<thead><tr><th>#</th><th>%s</th><th>%s</th><th>Leaf</th></tr></thead>\n",
+		html.EscapeString(report.BytesLabel()), html.EscapeString(report.BlocksLabel()),
+	)
+	fmt.Fprintln(w, "<tbody>")
+	for n, i := range pps {
+		pp := report.ProgramPoints[i]
+		leaf := ""
+		if frames := opts.visibleFrames(report, pp); len(frames) != 0 {
+			leaf = opts.displayFrame(report.GetFrame(frames[0]))
+		}
+		fmt.Fprintf(
+			w, "<tr><td>%d</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			opts.allocNumber(n+1, i), opts.bytes(pp.TotalBytes), opts.count(pp.TotalBlocks), html.EscapeString(leaf),
+		)
+	}
+	fmt.Fprintln(w, "</tbody>")
+	fmt.Fprintln(w, "</table>")
+}
+
+// markdownTableRows is the number of allocations in the table of -md-table,
+// unless -top gives another one.
+const markdownTableRows = 10
+
+// printMarkdownTable writes a Markdown table with one row for each of the
+// first program points pps, with the same columns as printCompact, followed
+// by the totals of all of them. It is meant to be posted as a comment, e.g.
+// on a pull request.
+func printMarkdownTable(w io.Writer, report *Report, pps []int, opts *options) {
+	rows := markdownTableRows
+	if opts.top > 0 {
+		rows = opts.top
+	}
+
+	fmt.Fprintf(w, "| # | %s | %s | Leaf |\n", report.BytesLabel(), report.BlocksLabel())
+	fmt.Fprintln(w, "|--:|--:|--:|:--|")
+	totalBytes, totalBlocks := 0, 0
+	for n, i := range pps {
+		pp := report.ProgramPoints[i]
+		totalBytes += pp.TotalBytes
+		totalBlocks += pp.TotalBlocks
+		if n >= rows {
+			continue
+		}
+		leaf := ""
+		if frames := opts.visibleFrames(report, pp); len(frames) != 0 {
+			leaf = markdownCode(truncateFrame(opts.displayFrame(report.GetFrame(frames[0])), compactLeafLen))
+		}
+		fmt.Fprintf(
+			w, "| %d | %s | %s | %s |\n",
+			opts.allocNumber(n+1, i), opts.bytes(pp.TotalBytes), opts.count(pp.TotalBlocks), leaf,
+		)
+	}
+	fmt.Fprintf(
+		w, "\n**Total:** %s %s in %s %s at %d sites",
+		opts.bytes(totalBytes), report.BytesLabel(), opts.count(totalBlocks), report.BlocksLabel(), len(pps),
+	)
+	if len(pps) > rows {
+		fmt.Fprintf(w, ", the first %d shown", rows)
+	}
+	fmt.Fprintln(w)
+}
+
+// markdownCode returns s as inline code for a Markdown table cell, with its
+// pipes escaped so they don't end the cell.
+func markdownCode(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// crlfWriter writes to w with the line endings converted to CRLF, for -crlf.
+type crlfWriter struct {
+	w io.Writer
+
+	// The last byte written was a '\r', so a following '\n' is already
+	// a CRLF.
+	cr bool
+}
+
+func (c *crlfWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(p) + bytes.Count(p, []byte("\n")))
+	for _, b := range p {
+		if b == '\n' && !c.cr {
+			buf.WriteByte('\r')
+		}
+		buf.WriteByte(b)
+		c.cr = b == '\r'
+	}
+	if _, err := c.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
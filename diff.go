@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// siteDiff holds the bytes allocated at one allocation site, identified by
+// its stack of resolved frames, in the current report and in the baselines.
+type siteDiff struct {
+	stack []string
+
+	// Bytes allocated in the current report.
+	bytes int
+
+	// Bytes allocated in the baselines, averaged over all of them. A baseline
+	// which doesn't contain the site counts as having allocated zero bytes.
+	baseBytes int
+
+	// The site appears in the current report and in at least one baseline.
+	inReport, inBase bool
+}
+
+func (d siteDiff) delta() int {
+	return d.bytes - d.baseBytes
+}
+
+func (d siteDiff) status() string {
+	switch {
+	case !d.inBase:
+		return "added"
+	case !d.inReport:
+		return "removed"
+	}
+	return "changed"
+}
+
+// diffSites matches the program points pps of report with the ones of each
+// baseline by their resolved stack and returns the sites whose bytes changed,
+// biggest change first.
+func diffSites(report *Report, pps []int, baselines []*Report, basePPs [][]int) []siteDiff {
+	sites := make(map[string]*siteDiff, len(pps))
+
+	get := func(r *Report, i int) *siteDiff {
+		key := r.StackKey(i)
+		d, ok := sites[key]
+		if !ok {
+			d = &siteDiff{stack: r.Stack(i)}
+			sites[key] = d
+		}
+		return d
+	}
+
+	for _, i := range pps {
+		d := get(report, i)
+		d.bytes += report.ProgramPoints[i].TotalBytes
+		d.inReport = true
+	}
+
+	for n, base := range baselines {
+		for _, i := range basePPs[n] {
+			d := get(base, i)
+			d.baseBytes += base.ProgramPoints[i].TotalBytes
+			d.inBase = true
+		}
+	}
+
+	diffs := make([]siteDiff, 0, len(sites))
+	for _, d := range sites {
+		d.baseBytes = (d.baseBytes + len(baselines)/2) / len(baselines)
+		if d.delta() == 0 && d.inReport && d.inBase {
+			continue
+		}
+		diffs = append(diffs, *d)
+	}
+
+	slices.SortFunc(diffs, func(a, b siteDiff) int {
+		if c := cmp.Compare(abs(b.delta()), abs(a.delta())); c != 0 {
+			return c
+		}
+		return slices.Compare(a.stack, b.stack)
+	})
+
+	return diffs
+}
+
+// addedOrRemoved returns the sites of diffs which appear only in the report
+// or only in the baselines, added ones first, each sorted by their stack.
+func addedOrRemoved(diffs []siteDiff) []siteDiff {
+	sites := slices.DeleteFunc(diffs, func(d siteDiff) bool { return d.inReport && d.inBase })
+	slices.SortFunc(sites, func(a, b siteDiff) int {
+		if a.inReport != b.inReport {
+			if a.inReport {
+				return -1
+			}
+			return 1
+		}
+		return slices.Compare(a.stack, b.stack)
+	})
+	return sites
+}
+
+// runDiff compares the program points pps of report with the ones of the
+// baselines given with -base and prints the sites which changed.
+func runDiff(w io.Writer, report *Report, pps []int, opts *options) error {
+	baselines := make([]*Report, 0, len(opts.baselines))
+	// The program points selected in each of the baselines.
+	baseSets := make([][]int, 0, len(opts.baselines))
+	for _, file := range opts.baselines {
+		base, err := opts.loadReport(file)
+		if err != nil {
+			return err
+		}
+		basePPs, err := opts.selectProgramPoints(base)
+		if err != nil {
+			return err
+		}
+		baselines = append(baselines, base)
+		baseSets = append(baseSets, basePPs)
+	}
+
+	diffs := diffSites(report, pps, baselines, baseSets)
+	if opts.diffMin > 0 {
+		diffs = slices.DeleteFunc(diffs, func(d siteDiff) bool { return abs(d.delta()) <= opts.diffMin })
+	}
+	if opts.diffSites {
+		diffs = addedOrRemoved(diffs)
+	}
+	if opts.top > 0 && len(diffs) > opts.top {
+		diffs = diffs[:opts.top]
+	}
+
+	if opts.diffJSON {
+		return printDiffJSON(w, diffs)
+	}
+	printDiff(w, report, diffs, len(baselines), opts)
+	return nil
+}
+
+// printDiff writes the sites returned by diffSites as text.
+func printDiff(w io.Writer, report *Report, diffs []siteDiff, baselines int, opts *options) {
+	printHeader(w, report, opts)
+	fmt.Fprintf(w, "Baselines: %d\n", baselines)
+
+	for n, d := range diffs {
+		fmt.Fprintf(w, "\n==== Site #%d ====\n", n+1)
+		if opts.diffSites {
+			fmt.Fprintln(w, d.status())
+			for _, frame := range opts.limitRepeats(opts.displayStack(d.stack)) {
+				fmt.Fprintf(w, "%s\n", frame)
+			}
+			continue
+		}
+		sign := "+"
+		if d.delta() < 0 {
+			sign = "-"
+		}
+		fmt.Fprintf(
+			w, "%s%s %s (%s -> %s, %s)\n",
+			sign, opts.bytes(abs(d.delta())), report.BytesLabel(), opts.bytes(d.baseBytes), opts.bytes(d.bytes), d.status(),
+		)
+		for _, frame := range opts.limitRepeats(opts.displayStack(d.stack)) {
+			fmt.Fprintf(w, "%s\n", frame)
+		}
+	}
+}
+
+// printDiffJSON writes the sites returned by diffSites as a JSON array, with
+// one object per line.
+func printDiffJSON(w io.Writer, diffs []siteDiff) error {
+	type jsonSite struct {
+		Stack    string `json:"stack"`
+		OldBytes int    `json:"oldBytes"`
+		NewBytes int    `json:"newBytes"`
+		Delta    int    `json:"delta"`
+		Status   string `json:"status"`
+	}
+
+	bw := bufio.NewWriter(w)
+
+	var site bytes.Buffer
+	enc := json.NewEncoder(&site)
+	enc.SetEscapeHTML(false)
+
+	bw.WriteString("[\n")
+	for n, d := range diffs {
+		site.Reset()
+		err := enc.Encode(jsonSite{
+			Stack:    strings.Join(d.stack, "\n"),
+			OldBytes: d.baseBytes,
+			NewBytes: d.bytes,
+			Delta:    d.delta(),
+			Status:   d.status(),
+		})
+		if err != nil {
+			return err
+		}
+		bw.Write(bytes.TrimSuffix(site.Bytes(), []byte("\n")))
+		if n != len(diffs)-1 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]\n")
+
+	return bw.Flush()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
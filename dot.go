@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// printDot writes the call graph of the program points pps as a Graphviz
+// digraph. Nodes are the resolved frames and edges go from callers to
+// callees, both labeled with the bytes allocated through them.
+func printDot(w io.Writer, r *Report, pps []int, opts *options) {
+	type edge struct {
+		from, to int
+	}
+
+	nodes := make(map[int]int)
+	var names []string
+	var nodeBytes []int
+	edgeBytes := make(map[edge]int)
+	var edges []edge
+
+	node := func(sym int) int {
+		n, ok := nodes[sym]
+		if !ok {
+			n = len(names)
+			nodes[sym] = n
+			names = append(names, r.Symbol(sym))
+			nodeBytes = append(nodeBytes, 0)
+		}
+		return n
+	}
+
+	for _, i := range pps {
+		bytes := r.ProgramPoints[i].TotalBytes
+		stack := r.StackSymbols(i)
+
+		// Count the bytes once per program point, even if a frame or a
+		// call appears many times in its stack because of recursion.
+		seenNodes := make(map[int]bool, len(stack))
+		seenEdges := make(map[edge]bool, len(stack))
+
+		prev := -1
+		for _, sym := range stack {
+			n := node(sym)
+			if !seenNodes[n] {
+				seenNodes[n] = true
+				nodeBytes[n] += bytes
+			}
+			if prev != -1 {
+				e := edge{from: prev, to: n}
+				if _, ok := edgeBytes[e]; !ok {
+					edges = append(edges, e)
+				}
+				if !seenEdges[e] {
+					seenEdges[e] = true
+					edgeBytes[e] += bytes
+				}
+			}
+			prev = n
+		}
+	}
+
+	fmt.Fprintln(w, "digraph dhatless {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for n, sym := range names {
+		fmt.Fprintf(w, "\tn%d [label=\"%s\\n%d bytes\"];\n", n, dotEscape(opts.displayFrame(sym)), nodeBytes[n])
+	}
+	for _, e := range edges {
+		fmt.Fprintf(w, "\tn%d -> n%d [label=\"%d bytes\"];\n", e.from, e.to, edgeBytes[e])
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// dotEscape escapes s to be used inside a double quoted DOT string.
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printDryRun writes one line for each program point of r, stating whether it
+// would be shown or by which filter it would be dropped. Program points are
+// identified by their index in the DHAT file, as printed by -debug-indices.
+func printDryRun(w io.Writer, r *Report, opts *options) error {
+	reasons := make([]string, len(r.ProgramPoints))
+	var kept []int
+	for i := range r.ProgramPoints {
+		if reasons[i] = opts.dropReason(r, i); reasons[i] == "" {
+			kept = append(kept, i)
+		}
+	}
+	if opts.filterCmd != "" {
+		passed, err := filterByCommand(r, kept, opts.filterCmd, opts.concurrency)
+		if err != nil {
+			return err
+		}
+		ok := make([]bool, len(r.ProgramPoints))
+		for _, i := range passed {
+			ok[i] = true
+		}
+		for _, i := range kept {
+			if !ok[i] {
+				reasons[i] = "rejected by -filter-cmd"
+			}
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "PP\t%s\tRESULT\n", strings.ToUpper(r.BytesLabel()))
+	for i, pp := range r.ProgramPoints {
+		result := "shown"
+		if reasons[i] != "" {
+			result = "dropped: " + reasons[i]
+		}
+		fmt.Fprintf(tw, "#%d\t%s\t%s\n", i, opts.bytes(pp.TotalBytes), result)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,28 @@
+package main
+
+// explanations describe the fields of the report for -explain, after the
+// documentation of the DHAT file format.
+var explanations = map[string]string{
+	"Command":    "the executed command",
+	"PID":        "the process ID of the profiled program",
+	"Mode":       "the DHAT invocation mode, which tells what the bytes and blocks count",
+	"t-end":      "the time at the end of the execution",
+	"Generated":  "when dhatless wrote this report",
+	"bytes":      "total bytes and number of blocks allocated at this site during the whole run",
+	"accesses":   "total reads and writes of the bytes of the blocks allocated at this site",
+	"r/w":        "reads per write of the blocks allocated at this site",
+	"at t-end":   "bytes allocated at this site and still not freed at the end of the execution",
+	"Annotation": "note for this site from the -annotate file",
+	"Retention":  "share of the maximum bytes of this site still allocated at t-end",
+
+	"Field accesses": "reads and writes of each field of the -struct-map, the most accessed first",
+}
+
+// explain returns the explanation of the field key to append to its line, if
+// -explain was given.
+func (o *options) explain(key string) string {
+	if !o.explainAll {
+		return ""
+	}
+	return "  (" + explanations[key] + ")"
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// filterByCommand returns the program points from pps for which the shell
+// command exits with status 0 when given the JSON of the program point on its
+// standard input. A process is started for every program point, up to workers
+// at a time.
+func filterByCommand(r *Report, pps []int, command string, workers int) ([]int, error) {
+	keep := make([]bool, len(pps))
+	errs := make([]error, len(pps))
+
+	sem := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+
+	for n, i := range pps {
+		input, err := json.Marshal(r.ProgramPoints[i])
+		if err != nil {
+			return nil, err
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(n int, input []byte) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			cmd := exec.Command("sh", "-c", command)
+			cmd.Stdin = bytes.NewReader(input)
+			cmd.Stdout = os.Stderr
+			cmd.Stderr = os.Stderr
+			err := cmd.Run()
+			var exitErr *exec.ExitError
+			switch {
+			case err == nil:
+				keep[n] = true
+			case errors.As(err, &exitErr):
+			default:
+				errs[n] = err
+			}
+		}(n, input)
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	kept := make([]int, 0, len(pps))
+	for n, i := range pps {
+		if keep[n] {
+			kept = append(kept, i)
+		}
+	}
+	return kept, nil
+}
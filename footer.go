@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/template"
+)
+
+// footer is what the -footer template is executed with.
+type footer struct {
+	File        string
+	Mode        string
+	Cmd         string
+	PID         string
+	TotalBytes  int
+	TotalBlocks int
+	ShownBytes  int
+	ShownBlocks int
+	Sites       int
+	Shown       int
+	Ignored     int
+}
+
+// parseFooter parses the text/template given to -footer.
+func parseFooter(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	t, err := template.New("footer").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("-footer: %w", err)
+	}
+	return t, nil
+}
+
+// printFooter writes the -footer template executed with the totals of the
+// report read from file and the ones of pps, the program points shown.
+func printFooter(w io.Writer, file string, r *Report, pps []int, opts *options) error {
+	f := footer{
+		File:    file,
+		Mode:    r.InvocationMode,
+		Cmd:     r.Cmd,
+		PID:     strconv.Itoa(r.PID),
+		Sites:   len(r.ProgramPoints),
+		Shown:   len(pps),
+		Ignored: len(r.ProgramPoints) - len(pps),
+	}
+	if opts.redact {
+		f.Cmd, f.PID = redacted, redacted
+	}
+	for _, pp := range r.ProgramPoints {
+		f.TotalBytes += pp.TotalBytes
+		f.TotalBlocks += pp.TotalBlocks
+	}
+	for _, i := range pps {
+		f.ShownBytes += r.ProgramPoints[i].TotalBytes
+		f.ShownBlocks += r.ProgramPoints[i].TotalBlocks
+	}
+
+	if err := opts.footerTmpl.Execute(w, f); err != nil {
+		return fmt.Errorf("-footer: %w", err)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// normalizeSpace replaces every run of whitespace in sym with a single space
+// and removes the leading and trailing ones, so that the same function
+// demangled with different spacing gives the same frame.
+func normalizeSpace(sym string) string {
+	return strings.Join(strings.Fields(sym), " ")
+}
+
+// foldTemplates replaces the argument list of every C++ template in sym
+// with "<>", e.g. "std::vector<int>::push_back" becomes
+// "std::vector<>::push_back". The angle brackets of operators like "<<" are
+// kept as they are. If the brackets don't balance, sym is returned unchanged.
+func foldTemplates(sym string) string {
+	var b strings.Builder
+	b.Grow(len(sym))
+	depth := 0
+	for i := 0; i < len(sym); i++ {
+		c := sym[i]
+		if depth == 0 && strings.HasSuffix(sym[:i], "operator") {
+			for i < len(sym) && strings.IndexByte("<>=-", sym[i]) >= 0 {
+				b.WriteByte(sym[i])
+				i++
+			}
+			if i == len(sym) {
+				break
+			}
+			c = sym[i]
+		}
+		switch c {
+		case '<':
+			if depth == 0 {
+				b.WriteByte(c)
+			}
+			depth++
+		case '>':
+			if depth == 0 {
+				b.WriteByte(c)
+				continue
+			}
+			depth--
+			if depth == 0 {
+				b.WriteByte(c)
+			}
+		default:
+			if depth == 0 {
+				b.WriteByte(c)
+			}
+		}
+	}
+	if depth != 0 {
+		return sym
+	}
+	return b.String()
+}
+
+// stripArgs removes the parameter list of the function in sym, together with
+// the qualifiers following it, e.g. "ns::f<int>(int, char) const" becomes
+// "ns::f<int>". Parentheses inside templates, lambdas and operator names, like
+// the ones of "operator()", are kept. If the brackets don't balance, sym is
+// returned unchanged.
+func stripArgs(sym string) string {
+	depth := 0
+	args := -1
+	for i := 0; i < len(sym); i++ {
+		if strings.HasSuffix(sym[:i], "operator") {
+			if strings.HasPrefix(sym[i:], "()") {
+				i++
+				continue
+			}
+			for i < len(sym) && strings.IndexByte("<>=-!+*/%&|^~[]", sym[i]) >= 0 {
+				i++
+			}
+			if i == len(sym) {
+				break
+			}
+		}
+		switch sym[i] {
+		case '(':
+			if depth == 0 {
+				args = i
+			}
+			depth++
+		case '<', '{':
+			depth++
+		case ')', '>', '}':
+			depth--
+			if depth < 0 {
+				return sym
+			}
+		}
+	}
+	if depth != 0 || args <= 0 {
+		return sym
+	}
+	return sym[:args]
+}
+
+// commonPrefixLen returns the number of outermost frames shared by all the
+// stacks, leaving at least one frame of each stack out of it.
+func commonPrefixLen(stacks [][]string) int {
+	if len(stacks) == 0 {
+		return 0
+	}
+	n := len(stacks[0])
+	for _, stack := range stacks {
+		n = min(n, len(stack)-1)
+		for j := 0; j < n; j++ {
+			if stack[j] != stacks[0][j] {
+				n = j
+				break
+			}
+		}
+	}
+	return max(n, 0)
+}
+
+// truncateFrame shortens sym to n characters, replacing the last one with an
+// ellipsis, if it is longer than that.
+func truncateFrame(sym string, n int) string {
+	if utf8.RuneCountInString(sym) <= n {
+		return sym
+	}
+	runes := []rune(sym)
+	return string(runes[:n-1]) + "…"
+}
+
+// frameStats returns, for every symbol of r, the number of program points
+// from pps which contain it and the sum of their bytes.
+func frameStats(r *Report, pps []int) (counts, bytes []int) {
+	counts = make([]int, len(r.symbols))
+	bytes = make([]int, len(r.symbols))
+
+	// Last program point which was counted for a symbol, to count each
+	// program point once even if the symbol appears in its stack many times.
+	last := make([]int, len(r.symbols))
+	for n := range last {
+		last[n] = -1
+	}
+
+	for _, i := range pps {
+		for _, frame := range r.ProgramPoints[i].Frames {
+			sym := r.frameSym[frame]
+			if last[sym] == i {
+				continue
+			}
+			last[sym] = i
+			counts[sym]++
+			bytes[sym] += r.ProgramPoints[i].TotalBytes
+		}
+	}
+
+	return counts, bytes
+}
+
+// printFrames writes every distinct symbol of the frame table of r, sorted,
+// followed by the number of program points from pps which contain it. The
+// [root] entry isn't a frame of any stack, so it is left out, while the bare
+// addresses valgrind couldn't resolve are listed.
+func printFrames(w io.Writer, r *Report, pps []int) {
+	counts, _ := frameStats(r, pps)
+
+	syms := make([]int, 0, len(r.symbols))
+	for i, frame := range r.FramesTable {
+		if frame != "[root]" {
+			syms = append(syms, r.frameSym[i])
+		}
+	}
+	slices.Sort(syms)
+	syms = slices.Compact(syms)
+	slices.SortFunc(syms, func(a, b int) int {
+		return strings.Compare(r.Symbol(a), r.Symbol(b))
+	})
+
+	for _, sym := range syms {
+		fmt.Fprintf(w, "%s\t%d\n", r.Symbol(sym), counts[sym])
+	}
+}
+
+// splitFrameLocation splits the resolved frame sym into the function and the
+// location valgrind printed after it between parentheses, e.g. "main (a.c:5)"
+// into "main" and "a.c:5", or "malloc (in /lib/libc.so)" into "malloc" and
+// "in /lib/libc.so". ok is false if sym has no location.
+func splitFrameLocation(sym string) (name, loc string, ok bool) {
+	if !strings.HasSuffix(sym, ")") {
+		return sym, "", false
+	}
+	i := strings.LastIndex(sym, " (")
+	if i < 0 {
+		return sym, "", false
+	}
+	return sym[:i], sym[i+2 : len(sym)-1], true
+}
+
+// frameSource returns the source file and line of the resolved frame sym, if
+// valgrind found them, e.g. "a.c" and 5 for "main (a.c:5)".
+func frameSource(sym string) (file string, line int, ok bool) {
+	_, loc, ok := splitFrameLocation(sym)
+	if !ok || strings.HasPrefix(loc, "in ") {
+		return "", 0, false
+	}
+	i := strings.LastIndexByte(loc, ':')
+	if i < 0 {
+		return "", 0, false
+	}
+	line, err := strconv.Atoi(loc[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return loc[:i], line, true
+}
+
+// sameAsEarlier replaces, with -max-frame-occurrences, the frames which were
+// already printed too many times.
+const sameAsEarlier = "... (same as earlier)"
+
+// limitRepeats returns the displayed frames of a stack with the ones printed
+// more than -max-frame-occurrences times in the whole output replaced by
+// sameAsEarlier, once for consecutive ones. The frames are counted as printed.
+func (o *options) limitRepeats(lines []string) []string {
+	if o.maxFrameOcc <= 0 {
+		return lines
+	}
+	if o.frameSeen == nil {
+		o.frameSeen = make(map[string]int)
+	}
+
+	limited := make([]string, 0, len(lines))
+	for _, line := range lines {
+		o.frameSeen[line]++
+		if o.frameSeen[line] <= o.maxFrameOcc {
+			limited = append(limited, line)
+		} else if len(limited) == 0 || limited[len(limited)-1] != sameAsEarlier {
+			limited = append(limited, sameAsEarlier)
+		}
+	}
+	return limited
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// group aggregates the program points which share the same key.
+type group struct {
+	// The key of the group, printed instead of a frame stack.
+	key []string
+
+	// The symbol indexes the key was made of.
+	syms []int
+
+	bytes, blocks int
+
+	// The number of program points in the group.
+	count int
+}
+
+// groupProgramPoints aggregates the program points pps of r by the symbols
+// returned by keyOf. The groups are returned sorted by bytes, biggest first.
+// With more than one worker, each chunk of pps is grouped on its own, then
+// the groups of the chunks are merged in order, which keeps the groups with
+// the same bytes in the same order as with one.
+func groupProgramPoints(r *Report, pps []int, keyOf func(i int) []int) []group {
+	cs := chunks(len(pps), r.workers)
+	chunkGroups := make([][]group, len(cs))
+	chunkKeys := make([][]string, len(cs))
+	forEachChunk(len(pps), r.workers, func(k int, c chunk) {
+		index := make(map[string]int, c.hi-c.lo)
+		groups := make([]group, 0, c.hi-c.lo)
+		var keys []string
+
+		var buf []byte
+		for _, i := range pps[c.lo:c.hi] {
+			syms := keyOf(i)
+			buf = buf[:0]
+			for _, sym := range syms {
+				buf = binary.AppendUvarint(buf, uint64(sym))
+			}
+			n, ok := index[string(buf)]
+			if !ok {
+				n = len(groups)
+				index[string(buf)] = n
+				groups = append(groups, group{syms: syms})
+				keys = append(keys, string(buf))
+			}
+			pp := r.ProgramPoints[i]
+			groups[n].bytes += pp.TotalBytes
+			groups[n].blocks += pp.TotalBlocks
+			groups[n].count++
+		}
+		chunkGroups[k], chunkKeys[k] = groups, keys
+	})
+
+	groups := chunkGroups[0]
+	if len(cs) > 1 {
+		index := make(map[string]int, len(pps))
+		groups = make([]group, 0, len(pps))
+		for k := range cs {
+			for j, g := range chunkGroups[k] {
+				n, ok := index[chunkKeys[k][j]]
+				if !ok {
+					index[chunkKeys[k][j]] = len(groups)
+					groups = append(groups, g)
+					continue
+				}
+				groups[n].bytes += g.bytes
+				groups[n].blocks += g.blocks
+				groups[n].count += g.count
+			}
+		}
+	}
+
+	for n := range groups {
+		groups[n].key = make([]string, len(groups[n].syms))
+		for j, sym := range groups[n].syms {
+			if sym == anySymbol {
+				groups[n].key[j] = anyAddress
+			} else {
+				groups[n].key[j] = r.Symbol(sym)
+			}
+		}
+	}
+
+	slices.SortStableFunc(groups, func(a, b group) int {
+		return cmp.Compare(b.bytes, a.bytes)
+	})
+
+	return groups
+}
+
+// groupByLabel aggregates the program points pps of r by the label returned
+// by labelOf, keyed as "kind: label". The groups are returned sorted by bytes,
+// biggest first.
+func groupByLabel(r *Report, pps []int, kind string, labelOf func(i int) string) []group {
+	index := make(map[string]int)
+	var groups []group
+	for _, i := range pps {
+		label := labelOf(i)
+		n, ok := index[label]
+		if !ok {
+			n = len(groups)
+			index[label] = n
+			groups = append(groups, group{key: []string{kind + ": " + label}})
+		}
+		pp := r.ProgramPoints[i]
+		groups[n].bytes += pp.TotalBytes
+		groups[n].blocks += pp.TotalBlocks
+		groups[n].count++
+	}
+
+	slices.SortStableFunc(groups, func(a, b group) int {
+		return cmp.Compare(b.bytes, a.bytes)
+	})
+
+	return groups
+}
+
+// printGroups writes the header of the report followed by the groups, with
+// the number of program points in each counted as members.
+func printGroups(w io.Writer, report *Report, groups []group, members string, opts *options) {
+	printHeader(w, report, opts)
+
+	for n, g := range groups {
+		fmt.Fprintf(w, "\n==== Group #%d ====\n", n+1)
+		fmt.Fprintf(
+			w, "%s %s in %s %s (%d %s)\n",
+			opts.bytes(g.bytes), report.BytesLabel(), opts.count(g.blocks), report.BlocksLabel(), g.count, members,
+		)
+		for _, frame := range opts.limitRepeats(opts.displayStack(g.key)) {
+			fmt.Fprintf(w, "%s\n", frame)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// heatColors are the ANSI 256-color codes used by -heat, from the smallest
+// allocations to the biggest.
+var heatColors = []int{46, 118, 190, 226, 214, 208, 202, 196}
+
+// useColors reports whether the output can be colored: text output to a
+// terminal which supports colors, as far as the environment tells.
+func useColors(opts *options) bool {
+	if opts.html || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || os.Getenv("TERM") == "" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// heatColor returns s colored by how big value is relative to maxValue.
+func heatColor(s string, value, maxValue int) string {
+	if maxValue <= 0 {
+		return s
+	}
+	n := value * (len(heatColors) - 1) / maxValue
+	return fmt.Sprintf("\x1b[38;5;%dm%s\x1b[0m", heatColors[n], s)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// bin is one bar of a histogram.
+type bin struct {
+	label string
+
+	// The number of program points and their bytes.
+	count, bytes int
+}
+
+// histogramWidth is the length of the longest bar of a histogram.
+const histogramWidth = 40
+
+// sizeBins returns the program points pps binned by their total bytes.
+func sizeBins(r *Report, pps []int) []bin {
+	bins := []bin{
+		{label: "0B - 64B"},
+		{label: "64B - 1KB"},
+		{label: "1KB - 1MB"},
+		{label: "> 1MB"},
+	}
+	limits := []int{64, 1 << 10, 1 << 20}
+
+	for _, i := range pps {
+		bytes := r.ProgramPoints[i].TotalBytes
+		n := 0
+		for n < len(limits) && bytes >= limits[n] {
+			n++
+		}
+		bins[n].count++
+		bins[n].bytes += bytes
+	}
+
+	return bins
+}
+
+// depthBins returns the program points pps binned by the number of frames
+// of their stacks.
+func depthBins(r *Report, pps []int) []bin {
+	limits := []int{1, 2, 4, 8, 16, 32, 64, 128}
+
+	bins := []bin{{label: "0 - 1"}}
+	for n := 1; n < len(limits); n++ {
+		lo, hi := limits[n-1]+1, limits[n]
+		if lo == hi {
+			bins = append(bins, bin{label: strconv.Itoa(hi)})
+		} else {
+			bins = append(bins, bin{label: fmt.Sprintf("%d - %d", lo, hi)})
+		}
+	}
+	bins = append(bins, bin{label: fmt.Sprintf("> %d", limits[len(limits)-1])})
+
+	for _, i := range pps {
+		pp := &r.ProgramPoints[i]
+		n := 0
+		for n < len(limits) && len(pp.Frames) > limits[n] {
+			n++
+		}
+		bins[n].count++
+		bins[n].bytes += pp.TotalBytes
+	}
+
+	return bins
+}
+
+// printHistogram writes the bins as a bar chart of their counts.
+func printHistogram(w io.Writer, report *Report, title string, bins []bin, opts *options) {
+	maxCount := 0
+	for _, b := range bins {
+		maxCount = max(maxCount, b.count)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tSites\tTotal %s\t\n", title, report.BytesLabel())
+	for _, b := range bins {
+		bar := 0
+		if maxCount > 0 {
+			bar = (b.count*histogramWidth + maxCount - 1) / maxCount
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", b.label, b.count, opts.bytes(b.bytes), strings.Repeat("#", bar))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"io"
+	"slices"
+)
+
+// printFramesTable writes a HTML table with every symbol found in the stacks
+// of the program points pps, the number of them which contain it and their
+// bytes. The table is sorted by bytes and can be sorted by any column by
+// clicking on its header.
+func printFramesTable(w io.Writer, r *Report, pps []int, opts *options) {
+	counts, bytes := frameStats(r, pps)
+
+	syms := make([]int, 0, len(counts))
+	for sym, n := range counts {
+		if n != 0 {
+			syms = append(syms, sym)
+		}
+	}
+	slices.SortStableFunc(syms, func(a, b int) int {
+		return cmp.Compare(bytes[b], bytes[a])
+	})
+
+	fmt.Fprintln(w, `<table id="frames-table"><thead><tr>`)
+	fmt.Fprintf(w, "<th>Frame</th><th>Allocations</th><th>%s</th>\n", html.EscapeString(r.BytesLabel()))
+	fmt.Fprintln(w, "</tr></thead><tbody>")
+	for _, sym := range syms {
+		fmt.Fprintf(
+			w, "<tr><td>%s</td><td>%d</td><td>%d</td></tr>\n",
+			html.EscapeString(opts.displayFrame(r.Symbol(sym))), counts[sym], bytes[sym],
+		)
+	}
+	fmt.Fprintln(w, "</tbody></table><br><hr><br>")
+	fmt.Fprint(w, framesTableScript)
+}
+
+const framesTableScript = `
+<script>
+
+document.querySelectorAll("#frames-table th").forEach((th, column) => {
+  let descending = false;
+  th.addEventListener("click", function(event) {
+    const tbody = document.querySelector("#frames-table tbody");
+    const rows = Array.from(tbody.rows);
+    const numeric = column > 0;
+    descending = !descending;
+    rows.sort((a, b) => {
+      const x = a.cells[column].textContent;
+      const y = b.cells[column].textContent;
+      const c = numeric ? Number(x) - Number(y) : x.localeCompare(y);
+      return descending ? -c : c;
+    });
+    rows.forEach(row => tbody.appendChild(row));
+  });
+});
+
+</script>
+
+`
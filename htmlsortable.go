@@ -0,0 +1,77 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// stableOrder returns the program points pps ordered by the hash of their
+// stack, for -stable-order, and the IDs of their HTML elements. The
+// allocations are numbered in this order, so their numbers and IDs are the
+// same ones whenever the report is regenerated.
+func stableOrder(r *Report, pps []int) ([]int, []string) {
+	type entry struct {
+		i    int
+		hash string
+	}
+	entries := make([]entry, len(pps))
+	for n, i := range pps {
+		entries[n] = entry{i: i, hash: r.StackID(i)}
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		if c := cmp.Compare(a.hash, b.hash); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.i, b.i)
+	})
+
+	ordered := make([]int, len(entries))
+	ids := make([]string, len(entries))
+	seen := make(map[string]int, len(entries))
+	for n, e := range entries {
+		ordered[n], ids[n] = e.i, "pp-"+e.hash
+
+		// The same stack can be in merged runs, or be made the same by
+		// the frame transformations.
+		seen[e.hash]++
+		if seen[e.hash] > 1 {
+			ids[n] += fmt.Sprintf("-%d", seen[e.hash])
+		}
+	}
+	return ordered, ids
+}
+
+// printSortButtons writes the buttons which sort the allocations of the HTML
+// report, wrapped by printReport in the #allocations element, by the data
+// attributes of their elements.
+func printSortButtons(w io.Writer) {
+	fmt.Fprintln(w, `<button class="btn-sort" data-key="order">Sort by Order</button>`)
+	fmt.Fprintln(w, `<button class="btn-sort" data-key="bytes">Sort by Bytes</button>`)
+	fmt.Fprintln(w, `<button class="btn-sort" data-key="blocks">Sort by Blocks</button>`)
+	fmt.Fprintln(w, "<br><br>")
+	fmt.Fprint(w, sortButtonsScript)
+}
+
+const sortButtonsScript = `
+<script>
+
+document.querySelectorAll(".btn-sort").forEach(button => {
+  let descending = button.dataset.key !== "order";
+  button.addEventListener("click", function(event) {
+    const allocations = document.getElementById("allocations");
+    const items = Array.from(allocations.children);
+    const key = button.dataset.key;
+    items.sort((a, b) => {
+      const c = Number(a.dataset[key]) - Number(b.dataset[key]);
+      return descending ? -c : c;
+    });
+    descending = !descending;
+    items.forEach(item => allocations.appendChild(item));
+  });
+});
+
+</script>
+
+`
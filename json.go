@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// jsonField is one of the keys of the objects written for each allocation
+// by -json.
+type jsonField struct {
+	name string
+
+	// Returns the value of the field for program point i, the n-th
+	// allocation of the output.
+	value func(r *Report, n, i int, opts *options) any
+}
+
+var jsonFields = []jsonField{
+	{"id", func(r *Report, n, i int, opts *options) any { return opts.allocNumber(n, i) }},
+	{"bytes", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].TotalBytes }},
+	{"blocks", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].TotalBlocks }},
+	{"totalLifetime", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].TotalLifetimesOfBlocks }},
+	{"maxBytes", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].MaxBytes }},
+	{"maxBlocks", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].MaxBlocks }},
+	{"gmaxBytes", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].BytesAtTgmax }},
+	{"gmaxBlocks", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].BlocksAtTgmax }},
+	{"endBytes", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].BytesAtTend }},
+	{"endBlocks", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].BlocksAtTend }},
+	{"reads", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].ReadsOfBlocks }},
+	{"writes", func(r *Report, n, i int, opts *options) any { return r.ProgramPoints[i].WritesOfBlocks }},
+	{"frames", func(r *Report, n, i int, opts *options) any {
+		if opts.jsonFrames == "structured" {
+			return structuredStack(opts.visibleStack(r, r.ProgramPoints[i]), opts)
+		}
+		return opts.displayStack(opts.visibleStack(r, r.ProgramPoints[i]))
+	}},
+}
+
+// jsonFrame is a frame of the JSON output with -json-frames=structured: its
+// function and source location or, if the frame doesn't have those, the raw
+// frame. Inlined is set for the frames -fold-inlined folded others into.
+type jsonFrame struct {
+	Function string `json:"function,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Raw      string `json:"raw,omitempty"`
+	Inlined  bool   `json:"inlined,omitempty"`
+}
+
+// structuredStack returns the displayed frames of stack, as by displayStack,
+// split in their parts.
+func structuredStack(stack []string, opts *options) []jsonFrame {
+	lines := opts.displayStack(stack)
+	frames := make([]jsonFrame, len(lines))
+	for n, sym := range lines {
+		sym, inlined := strings.CutSuffix(sym, inlinedSuffix)
+		file, line, ok := frameSource(sym)
+		if !ok {
+			frames[n] = jsonFrame{Raw: sym, Inlined: inlined}
+			continue
+		}
+		function, _, _ := splitFrameLocation(sym)
+		frames[n] = jsonFrame{Function: function, File: file, Line: line, Inlined: inlined}
+	}
+	return frames
+}
+
+// jsonFieldNames returns the names of all JSON fields, for use in messages.
+func jsonFieldNames() string {
+	names := make([]string, len(jsonFields))
+	for n, f := range jsonFields {
+		names[n] = f.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// selectJSONFields returns the JSON fields named in the comma separated list,
+// in their usual order, or all of them if list is empty.
+func selectJSONFields(list string) ([]jsonField, error) {
+	if list == "" {
+		return jsonFields, nil
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, f := range jsonFields {
+			if f.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown JSON field %q, valid fields are: %s", name, jsonFieldNames())
+		}
+		selected[name] = true
+	}
+
+	fields := make([]jsonField, 0, len(selected))
+	for _, f := range jsonFields {
+		if selected[f.name] {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// jsonEnvelope is the object in which -json-envelope wraps the allocations,
+// with the fields of the header of the report. The command and the PID are
+// left out with -redact, and the command is also split with -split-cmd.
+type jsonEnvelope struct {
+	Cmd       string   `json:"cmd,omitempty"`
+	Program   string   `json:"program,omitempty"`
+	Args      []string `json:"args,omitempty"`
+	PID       int      `json:"pid,omitempty"`
+	Mode      string   `json:"mode"`
+	TimeAtEnd int      `json:"te"`
+	TimeUnit  string   `json:"tu"`
+}
+
+// printJSON writes the program points pps as a JSON array, with one object
+// per line, or with -json-envelope as the "allocations" of a jsonEnvelope.
+func printJSON(w io.Writer, r *Report, pps []int, opts *options) error {
+	bw := bufio.NewWriter(w)
+
+	var value bytes.Buffer
+	enc := json.NewEncoder(&value)
+	enc.SetEscapeHTML(false)
+
+	if opts.jsonEnv {
+		env := jsonEnvelope{Mode: r.InvocationMode, TimeAtEnd: r.TimeAtEnd, TimeUnit: r.TimeUnit}
+		if !opts.redact {
+			env.Cmd, env.PID = r.Cmd, r.PID
+			if opts.splitCmd {
+				env.Program, env.Args = splitCommand(r.Cmd)
+			}
+		}
+		if err := enc.Encode(env); err != nil {
+			return err
+		}
+		bw.Write(bytes.TrimSuffix(value.Bytes(), []byte("}\n")))
+		bw.WriteString(`,"allocations":`)
+	}
+
+	bw.WriteString("[\n")
+	for n, i := range pps {
+		bw.WriteString("{")
+		for k, f := range opts.jsonFields {
+			value.Reset()
+			if err := enc.Encode(f.value(r, n+1, i, opts)); err != nil {
+				return err
+			}
+			if k != 0 {
+				bw.WriteString(",")
+			}
+			fmt.Fprintf(bw, "%q:%s", f.name, bytes.TrimSuffix(value.Bytes(), []byte("\n")))
+		}
+		bw.WriteString("}")
+		if n != len(pps)-1 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]")
+	if opts.jsonEnv {
+		bw.WriteString("}")
+	}
+	bw.WriteString("\n")
+
+	return bw.Flush()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// leafSymbol returns the symbol index of the innermost frame of the program
+// point i which is displayed, or -1 if all of them are hidden.
+func (o *options) leafSymbol(r *Report, i int) int {
+	frames := o.visibleFrames(r, r.ProgramPoints[i])
+	if len(frames) == 0 {
+		return -1
+	}
+	return r.frameSym[frames[0]]
+}
+
+// capPerLeaf keeps at most n of the program points pps with the same leaf
+// frame, the ones with the most bytes, in their original order. For every
+// leaf which had more, a note with the number of the left out ones is also
+// returned.
+func capPerLeaf(r *Report, pps []int, n int, opts *options) ([]int, []string) {
+	bySize := slices.Clone(pps)
+	slices.SortStableFunc(bySize, func(a, b int) int {
+		return cmp.Compare(r.ProgramPoints[b].TotalBytes, r.ProgramPoints[a].TotalBytes)
+	})
+
+	shown := make(map[int]int)
+	dropped := make(map[int]int)
+	keep := make(map[int]bool, len(pps))
+	for _, i := range bySize {
+		leaf := opts.leafSymbol(r, i)
+		if shown[leaf] < n {
+			shown[leaf]++
+			keep[i] = true
+		} else {
+			dropped[leaf]++
+		}
+	}
+
+	var notes []string
+	kept := make([]int, 0, len(keep))
+	for _, i := range pps {
+		if keep[i] {
+			kept = append(kept, i)
+			continue
+		}
+		leaf := opts.leafSymbol(r, i)
+		if dropped[leaf] == 0 {
+			continue
+		}
+		name := "<no frames>"
+		if leaf >= 0 {
+			name = opts.displayFrame(r.Symbol(leaf))
+		}
+		notes = append(notes, fmt.Sprintf("+%d more sites under %s", dropped[leaf], name))
+		dropped[leaf] = 0
+	}
+
+	return kept, notes
+}
+
+// dominantLeaf is a leaf frame found by -dominant.
+type dominantLeaf struct {
+	// The symbol index of the leaf frame, -1 for the program points whose
+	// frames are all hidden.
+	sym int
+
+	bytes, sites int
+}
+
+// dominantLeaves returns the leaf frames of the program points pps whose
+// bytes, summed over all of them, are more than percent of the bytes of pps,
+// the biggest first, and the bytes of pps.
+func dominantLeaves(r *Report, pps []int, percent float64, opts *options) ([]dominantLeaf, int) {
+	total := 0
+	index := make(map[int]int)
+	var leaves []dominantLeaf
+	for _, i := range pps {
+		leaf := opts.leafSymbol(r, i)
+		n, ok := index[leaf]
+		if !ok {
+			n = len(leaves)
+			index[leaf] = n
+			leaves = append(leaves, dominantLeaf{sym: leaf})
+		}
+		leaves[n].bytes += r.ProgramPoints[i].TotalBytes
+		leaves[n].sites++
+		total += r.ProgramPoints[i].TotalBytes
+	}
+
+	leaves = slices.DeleteFunc(leaves, func(l dominantLeaf) bool {
+		return float64(l.bytes)*100 <= float64(total)*percent
+	})
+	slices.SortStableFunc(leaves, func(a, b dominantLeaf) int {
+		return cmp.Compare(b.bytes, a.bytes)
+	})
+	return leaves, total
+}
+
+// printDominant writes the leaves returned by dominantLeaves, one per line,
+// or that there are none.
+func printDominant(w io.Writer, r *Report, leaves []dominantLeaf, total int, percent float64, opts *options) {
+	if len(leaves) == 0 {
+		fmt.Fprintf(
+			w, "No leaf frame accounts for more than %g%% of the %s %s\n",
+			percent, opts.bytes(total), r.BytesLabel(),
+		)
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "SHARE\t%s\tSITES\tLEAF\n", strings.ToUpper(r.BytesLabel()))
+	for _, l := range leaves {
+		name := "<no frames>"
+		if l.sym >= 0 {
+			name = opts.displayFrame(r.Symbol(l.sym))
+		}
+		fmt.Fprintf(
+			tw, "%.1f%%\t%s\t%d\t%s\n",
+			float64(l.bytes)*100/float64(total), opts.bytes(l.bytes), l.sites, name,
+		)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,37 @@
+package main
+
+// likelyLeakRetention is the retention from which -flag-leaks tags the
+// allocations as likely leaks.
+const likelyLeakRetention = 0.9
+
+// likelyLeak is the tag of the allocations flagged by -flag-leaks.
+const likelyLeak = "[likely leak]"
+
+// retention returns the share of the maximum bytes of pp still allocated at
+// t-end: close to 1 for memory held until the end, 0 for transient memory.
+func retention(pp ProgramPoint) float64 {
+	if pp.MaxBytes == 0 {
+		return 0
+	}
+	return float64(pp.BytesAtTend) / float64(pp.MaxBytes)
+}
+
+// isLikelyLeak reports whether pp holds most of its memory until the end.
+func isLikelyLeak(pp ProgramPoint) bool {
+	return pp.BytesAtTend > 0 && retention(pp) >= likelyLeakRetention
+}
+
+// leaksFirst moves the likely leaks of the program points pps first, keeping
+// the order of both the leaks and the other allocations.
+func leaksFirst(r *Report, pps []int) {
+	leaks := make([]int, 0, len(pps))
+	var others []int
+	for _, i := range pps {
+		if isLikelyLeak(r.ProgramPoints[i]) {
+			leaks = append(leaks, i)
+		} else {
+			others = append(others, i)
+		}
+	}
+	copy(pps[copy(pps, leaks):], others)
+}
@@ -1,24 +1,51 @@
 package main
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"html"
+	"io"
+	"net/http"
 	"os"
+	"regexp"
+	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
+	"runtime/trace"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 )
 
 const usage = `Usage: dhatless [FLAGS] DHAT_FILE
 
 Generate a report with all allocations recorded in the given DHAT output file.
+The DHAT file can also be given as a http:// or https:// URL, from which it is
+downloaded.
+If the DHAT file contains several concatenated DHAT runs, their allocations are
+merged in one report. All runs must use the same mode.
 
 By default, the generated report will be written to STDOUT as regular text.
 Use -html to generate a HTML report.
 
+Use -base to compare the report against a baseline DHAT file instead.
+Allocations are matched by their frame stack and only the ones whose bytes
+changed are printed. If -base is given multiple times, the bytes of each
+allocation are averaged over all the baselines, which reduces the noise of
+comparing single runs. Use -diff-sites to print only the sites which were added
+or removed.
+
+Use -trend to follow the allocations over a series of DHAT files, from oldest
+to newest, the given DHAT file being the newest one. The bytes of each site are
+printed for every file, and the sites whose bytes grow in each file come first.
+
 Specific allocations can be ignored by using a ignore file.
 A ignore file contains keywords(e.g. my_function) which will be searched in the
 frame stack of all allocations.
@@ -29,6 +56,26 @@ The ignore file must contain a list of keywords separated by newline('\n').
 Whitespaces(' ' and '\t') are trimmed from the start and end of the lines.
 Empty lines and comment lines(which start with '#') are ignored.
 
+The ignore file can also contain keywords for allocations which must be kept,
+listed after a '[keep]' line. If at least one such keyword is given, only the
+allocations whose frame stack contains one of them are added to the report.
+A '[ignore]' line switches back to keywords for ignored allocations, which is
+also what the lines before the first section header are.
+
+Use -filter-cmd for filtering which can't be expressed with the ignore file or other flags.
+The given command is run by the shell once for every allocation, with the JSON
+of the allocation(as found in the DHAT file) on its standard input, and the
+allocation is kept only if the command exits with status 0. Starting a process
+for every allocation is slow for big reports, so it's best combined with
+other filters, which are applied first. Up to one command per CPU is run at a
+time.
+
+Default values for the flags can be given in a config file, with -config.
+Each line of the config file has the form 'name = value', where name is the
+name of a flag without the leading '-'. Flags given on the command line take
+precedence over the ones in the config file. Empty lines and comment lines are
+ignored, as in the ignore file.
+
 FLAGS:
 `
 
@@ -39,6 +86,495 @@ func main() {
 	}
 }
 
+// options holds the values of the command line flags which control how the
+// DHAT reports are loaded, filtered and printed.
+type options struct {
+	ignoreFile  string
+	ignoreRegex string
+	html        bool
+	htmlRaw     bool
+	sortBy      string
+	rwRatio     bool
+	writeOnly   bool
+	foldTmpl    bool
+	normalizeWS bool
+	summaryLine bool
+	memStats    bool
+	summaryOut  string
+	footer      string
+	timeUnit    string
+	baselines   stringList
+	top         int
+	dumpFrames  bool
+	frameMaxLen int
+	groupDepth  int
+	dot         bool
+	redact      bool
+	liveAt      string
+	filterCmd   string
+	histogram   bool
+	depthHist   bool
+	stableIDs   bool
+	stableOrd   bool
+	tui         bool
+	hotAccess   bool
+	maxFrameOcc int
+	origIndex   bool
+	flagLeaks   bool
+	concurrency int
+	symbolMap   string
+	mdTable     bool
+	watch       bool
+	compact     bool
+	unused      bool
+	topPercent  float64
+	showUntil   int
+	sortDir     string
+	hideFrames  stringList
+	debugIdx    bool
+	maxPPs      int
+	minBytes    int
+	minBlocks   int
+	sarif       bool
+	trimPrefix  stringList
+	pager       bool
+	htmlFrames  bool
+	htmlSort    bool
+	partialOK   bool
+	diffMin     int
+	byThread    bool
+	modules     string
+	byModule    bool
+	structFile  string
+	threadExpr  string
+	anyVersion  bool
+	sample      int
+	seed        int64
+	annotate    string
+	nonzeroEnd  bool
+	diffJSON    bool
+	trimCommon  bool
+	sep         string
+	splitCmd    bool
+	heat        bool
+	explainAll  bool
+	trend       string
+	htmlFrag    bool
+	byStack     bool
+	crlf        bool
+	bom         bool
+	json        bool
+	jsonEnv     bool
+	jsonFrames  string
+	dominant    float64
+	jsonFieldsL string
+	thousands   string
+	dryRun      bool
+	hexBytes    bool
+	diffSites   bool
+	sanitize    string
+	escapeCtl   bool
+	maxPerLeaf  int
+	binary      string
+	buildID     string
+	listUnres   bool
+	noTimestamp bool
+	stripArgs   bool
+	foldInlined bool
+
+	// Derived from the flags above by init.
+	sortKey    sortKey
+	sortAsc    bool
+	ignoreList []string
+	ignoreRes  []*regexp.Regexp
+	modRules   []moduleRule
+	structMap  map[int][]structField
+	keepList   []string
+	hideRes    []*regexp.Regexp
+	jsonFields []jsonField
+	addrCache  map[string]string
+	threadRe   *regexp.Regexp
+	siteNotes  map[string]string
+	colors     bool
+	trendFiles []string
+	frameSeen  map[string]int
+	symbols    *symbolMap
+	footerTmpl *template.Template
+}
+
+func (o *options) init() error {
+	if o.sortBy != "" {
+		var ok bool
+		if o.sortKey, ok = sortKeys[o.sortBy]; !ok {
+			return fmt.Errorf("unknown sort key %q, valid keys are: %s", o.sortBy, sortKeyNames())
+		}
+	}
+
+	switch o.sortDir {
+	case "":
+		o.sortAsc = o.sortKey.ascending
+	case "asc":
+		o.sortAsc = true
+	case "desc":
+		o.sortAsc = false
+	default:
+		return fmt.Errorf("unknown -sort-dir %q, must be asc or desc", o.sortDir)
+	}
+
+	if o.nonzeroEnd {
+		if o.liveAt != "" && o.liveAt != "end" {
+			return fmt.Errorf("-nonzero-end is -live-at end, it cannot be used with -live-at %s", o.liveAt)
+		}
+		o.liveAt = "end"
+	}
+	switch o.liveAt {
+	case "", "gmax", "end":
+	default:
+		return fmt.Errorf("unknown -live-at time %q, must be gmax or end", o.liveAt)
+	}
+
+	if o.topPercent < 0 || o.topPercent > 100 {
+		return fmt.Errorf("-top-percent must be between 0 and 100")
+	}
+	if o.dominant < 0 || o.dominant > 100 {
+		return fmt.Errorf("-dominant must be between 0 and 100")
+	}
+	if o.showUntil < 0 {
+		return fmt.Errorf("-show-until must be positive")
+	}
+
+	switch o.sanitize {
+	case "":
+		if o.html || o.htmlFrag {
+			o.sanitize = "replace"
+		}
+	case "replace", "hex", "off":
+	default:
+		return fmt.Errorf("unknown -sanitize-utf8 mode %q, must be replace, hex or off", o.sanitize)
+	}
+
+	if o.diffSites && len(o.baselines) == 0 {
+		return fmt.Errorf("-diff-sites needs at least one -base")
+	}
+	if o.diffMin > 0 && len(o.baselines) == 0 {
+		return fmt.Errorf("-diff-min needs at least one -base")
+	}
+	if o.tui && (o.watch || o.pager) {
+		return fmt.Errorf("-tui cannot be used with -watch or -pager")
+	}
+	if o.stableOrd && !o.html {
+		return fmt.Errorf("-stable-order needs -html")
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = runtime.NumCPU()
+	}
+	if o.footer != "" && (o.json || o.diffJSON || o.sarif || o.dot || o.html || o.htmlFrag || o.tui) {
+		return fmt.Errorf("-footer cannot be used with -json, -diff-json, -sarif, -dot, -html, -html-fragment or -tui")
+	}
+	if o.jsonEnv && !o.json {
+		return fmt.Errorf("-json-envelope needs -json")
+	}
+	if o.jsonFrames != "flat" && o.jsonFrames != "structured" {
+		return fmt.Errorf("unknown -json-frames %q, must be flat or structured", o.jsonFrames)
+	}
+	if o.diffJSON && len(o.baselines) == 0 {
+		return fmt.Errorf("-diff-json needs at least one -base")
+	}
+
+	for _, expr := range o.hideFrames {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("-hide-frame: %w", err)
+		}
+		o.hideRes = append(o.hideRes, re)
+	}
+
+	var err error
+	if o.threadRe, err = regexp.Compile(o.threadExpr); err != nil {
+		return fmt.Errorf("-thread-re: %w", err)
+	}
+
+	if o.jsonFields, err = selectJSONFields(o.jsonFieldsL); err != nil {
+		return err
+	}
+
+	if o.siteNotes, err = parseAnnotateFile(o.annotate); err != nil {
+		return err
+	}
+
+	if o.footerTmpl, err = parseFooter(o.footer); err != nil {
+		return err
+	}
+
+	if o.trend != "" {
+		o.trendFiles = strings.Split(o.trend, ",")
+	}
+
+	if o.ignoreRes, err = parseIgnoreRegexFile(o.ignoreRegex); err != nil {
+		return err
+	}
+
+	if o.byModule && o.modules == "" {
+		return fmt.Errorf("-by-module needs a -modules file")
+	}
+	if o.modRules, err = parseModulesFile(o.modules); err != nil {
+		return err
+	}
+
+	if o.structMap, err = parseStructMap(o.structFile); err != nil {
+		return err
+	}
+
+	if o.symbols, err = parseSymbolMap(o.symbolMap); err != nil {
+		return err
+	}
+
+	o.ignoreList, o.keepList, err = parseIgnoreFile(o.ignoreFile)
+	return err
+}
+
+// loadReport parses the given DHAT file and applies the frame transformations
+// selected by the flags.
+func (o *options) loadReport(file string) (*Report, error) {
+	report, err := parseReport(file, o.partialOK, o.concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	const dhatVersion = 2
+	if report.Version != dhatVersion && o.anyVersion {
+		fmt.Fprintf(
+			os.Stderr, "warning: DHAT report version %d is not supported, the output may be wrong or incomplete\n",
+			report.Version,
+		)
+	} else if report.Version != dhatVersion {
+		return nil, fmt.Errorf(
+			"DHAT report version %d is not supported, only version %d is supported (use -force-version to try anyway)",
+			report.Version, dhatVersion,
+		)
+	}
+
+	if o.maxPPs > 0 && len(report.ProgramPoints) > o.maxPPs {
+		return nil, fmt.Errorf(
+			"%s has %d allocations, more than the %d allowed by -max-pps; "+
+				"raise -max-pps to process it anyway, with -i, -top or other filters to keep the report small",
+			file, len(report.ProgramPoints), o.maxPPs,
+		)
+	}
+
+	// The addresses of the symbol map take precedence over addr2line, which
+	// only looks up the ones still unresolved, and its names are also
+	// substituted in the symbols found by addr2line.
+	if o.symbols != nil {
+		o.symbols.mapAddresses(report)
+	}
+	if o.binary != "" {
+		o.checkBuildID(os.Stderr, report)
+		if err := o.resolveWithBinary(report); err != nil {
+			return nil, err
+		}
+	}
+	if o.symbols != nil {
+		o.symbols.mapNames(report)
+	}
+
+	if o.normalizeWS {
+		report.MapFrames(normalizeSpace)
+	}
+	if o.foldTmpl {
+		report.MapFrames(foldTemplates)
+	}
+
+	return report, nil
+}
+
+// displayFrame returns how the resolved frame sym is displayed, which can
+// differ from the frame used for matching and grouping.
+func (o *options) displayFrame(sym string) string {
+	if o.stripArgs {
+		if name, loc, ok := splitFrameLocation(sym); ok {
+			sym = stripArgs(name) + " (" + loc + ")"
+		} else {
+			sym = stripArgs(sym)
+		}
+	}
+	if len(o.trimPrefix) != 0 {
+		if name, loc, ok := splitFrameLocation(sym); ok {
+			if path, found := strings.CutPrefix(loc, "in "); found {
+				loc = "in " + o.trimPath(path)
+			} else {
+				loc = o.trimPath(loc)
+			}
+			sym = name + " (" + loc + ")"
+		}
+	}
+	sym = sanitizeUTF8(sym, o.sanitize)
+	if o.escapeCtl {
+		sym = escapeControl(sym)
+	}
+	if o.frameMaxLen > 0 {
+		sym = truncateFrame(sym, o.frameMaxLen)
+	}
+	return sym
+}
+
+// count formats the byte or block count n, grouping its digits in thousands
+// if -thousands was given.
+func (o *options) count(n int) string {
+	s := strconv.Itoa(n)
+	if o.thousands == "" {
+		return s
+	}
+
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, c := range s {
+		if i != 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(o.thousands)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// bytes formats the byte count n, in hexadecimal if -hex-bytes was given.
+func (o *options) bytes(n int) string {
+	if o.hexBytes {
+		return fmt.Sprintf("%#x", n)
+	}
+	return o.count(n)
+}
+
+// allocNumber returns the number which the n-th allocation of the output, the
+// program point i, is labelled with: n, or i with -orig-index.
+func (o *options) allocNumber(n, i int) int {
+	if o.origIndex {
+		return i
+	}
+	return n
+}
+
+// inlinedSuffix follows the frames which others are folded into by
+// -fold-inlined.
+const inlinedSuffix = " [inlined]"
+
+// displayStack returns how the resolved frames of stack are displayed, as
+// by displayFrame. With -fold-inlined, consecutive frames with the same source
+// location are shown as the first of them, followed by "[inlined]".
+func (o *options) displayStack(stack []string) []string {
+	lines := make([]string, 0, len(stack))
+	prevFile, prevLine, folded := "", 0, false
+	for _, sym := range stack {
+		file, line, ok := frameSource(sym)
+		if o.foldInlined && ok && len(lines) != 0 && file == prevFile && line == prevLine {
+			if !folded {
+				lines[len(lines)-1] += inlinedSuffix
+				folded = true
+			}
+			continue
+		}
+		if !ok {
+			file, line = "", 0
+		}
+		lines = append(lines, o.displayFrame(sym))
+		prevFile, prevLine, folded = file, line, false
+	}
+	return lines
+}
+
+// visibleStack returns the resolved frames of pp which are displayed,
+// outermost first.
+func (o *options) visibleStack(r *Report, pp ProgramPoint) []string {
+	frames := o.visibleFrames(r, pp)
+	stack := make([]string, 0, len(frames))
+	for j := len(frames) - 1; j >= 0; j-- {
+		stack = append(stack, r.GetFrame(frames[j]))
+	}
+	return stack
+}
+
+// trimPath removes the first of the prefixes given with -trim-prefix which
+// path starts with.
+func (o *options) trimPath(path string) string {
+	for _, prefix := range o.trimPrefix {
+		if after, ok := strings.CutPrefix(path, prefix); ok {
+			return after
+		}
+	}
+	return path
+}
+
+// visibleFrames returns the frames of pp which are displayed, leaving out the
+// ones hidden with -hide-frame.
+func (o *options) visibleFrames(r *Report, pp ProgramPoint) []int {
+	if len(o.hideRes) == 0 {
+		return pp.Frames
+	}
+	frames := make([]int, 0, len(pp.Frames))
+	for _, frame := range pp.Frames {
+		sym := r.GetFrame(frame)
+		if !slices.ContainsFunc(o.hideRes, func(re *regexp.Regexp) bool { return re.MatchString(sym) }) {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+// selectProgramPoints returns the indexes of the program points of r which
+// pass the filters selected by the flags, in their original order.
+func (o *options) selectProgramPoints(r *Report) ([]int, error) {
+	keep := make([]bool, len(r.ProgramPoints))
+	forEachChunk(len(r.ProgramPoints), o.concurrency, func(_ int, c chunk) {
+		for i := c.lo; i < c.hi; i++ {
+			keep[i] = o.dropReason(r, i) == ""
+		}
+	})
+	pps := make([]int, 0, len(r.ProgramPoints))
+	for i, ok := range keep {
+		if ok {
+			pps = append(pps, i)
+		}
+	}
+	if o.filterCmd != "" {
+		return filterByCommand(r, pps, o.filterCmd, o.concurrency)
+	}
+	return pps, nil
+}
+
+// dropReason returns why the program point i of r is left out by the filters
+// selected by the flags, other than -filter-cmd, or "" if it is kept.
+func (o *options) dropReason(r *Report, i int) string {
+	pp := r.ProgramPoints[i]
+	switch {
+	case len(o.keepList) != 0 && !hasAnyKeyword(*r, i, o.keepList):
+		return "no keep keyword matched"
+	case hasAnyKeyword(*r, i, o.ignoreList):
+		return fmt.Sprintf("ignore keyword %q matched", matchingKeyword(*r, i, o.ignoreList))
+	case matchingRegexp(*r, i, o.ignoreRes) != nil:
+		return fmt.Sprintf("ignore regexp %q matched", matchingRegexp(*r, i, o.ignoreRes))
+	case pp.TotalBytes < o.minBytes:
+		return fmt.Sprintf("below -min-bytes %d", o.minBytes)
+	case pp.TotalBlocks < o.minBlocks:
+		return fmt.Sprintf("below -min-blocks %d", o.minBlocks)
+	case o.writeOnly && pp.ReadsOfBlocks != 0:
+		return "read, excluded by -write-only"
+	case o.unused && (pp.ReadsOfBlocks != 0 || pp.WritesOfBlocks != 0):
+		return "accessed, excluded by -unused"
+	case len(o.hideRes) != 0 && len(o.visibleFrames(r, pp)) == 0:
+		return "all frames hidden by -hide-frame"
+	case o.liveAt == "gmax" && pp.BytesAtTgmax == 0:
+		return "not live at t-gmax"
+	case o.liveAt == "end" && pp.BytesAtTend == 0:
+		return "not live at t-end"
+	}
+	return ""
+}
+
 func mainErr(args []string) error {
 	fset := flag.NewFlagSet("root", flag.ContinueOnError)
 
@@ -48,11 +584,312 @@ func mainErr(args []string) error {
 		fmt.Fprintln(os.Stderr, "")
 	}
 
-	ignoreFile := fset.String("i", "", "`File` with keywords to ignored, one per line")
-	outputHtml := fset.Bool("html", false, "Generate HTML output")
+	var opts options
+
+	fset.StringVar(&opts.ignoreFile, "i", "", "`File` with keywords to ignored, one per line")
+	fset.StringVar(&opts.ignoreRegex, "ir", "", "`File` with regular expressions of the frames to ignore, one per line")
+	fset.BoolVar(&opts.html, "html", false, "Generate HTML output")
+	fset.BoolVar(&opts.htmlRaw, "html-raw", false, "Include the raw JSON of each allocation in the HTML output")
 	printVersion := fset.Bool("version", false, "Print version")
+	printSchemaOnly := fset.Bool(
+		"print-schema", false,
+		"Print the fields of the DHAT file format which are understood, with their JSON keys, and exit",
+	)
 	cpuProfile := fset.Bool("profile-cpu", false, "Write CPU profile")
-	memProfile := fset.Bool("profile-mem", false, "Write memory profile")
+	memLimit := fset.Int64(
+		"mem-limit", 0,
+		"Make the garbage collector work harder to keep the memory used by dhatless under `N` bytes",
+	)
+	fset.BoolVar(
+		&opts.memStats, "profile-mem", false,
+		"Write memory profile, and the sizes of the parts of the decoded DHAT file to STDERR",
+	)
+	traceFile := fset.String("profile-trace", "", "Write execution trace to `file`")
+	configFile := fset.String("config", "", "Read default flag values from `file`")
+	fset.StringVar(&opts.sortBy, "sort", "", "Sort allocations by `key` (one of: "+sortKeyNames()+")")
+	fset.StringVar(
+		&opts.sortDir, "sort-dir", "",
+		"Sort in the given `direction`, asc or desc (default desc, except for name and stack which are asc)",
+	)
+	fset.BoolVar(&opts.rwRatio, "rw-ratio", false, "Print the ratio of reads to writes of each allocation")
+	fset.BoolVar(&opts.writeOnly, "write-only", false, "Show only allocations which are never read")
+	fset.BoolVar(
+		&opts.foldTmpl, "fold-templates", false,
+		"Replace C++ template arguments with <>, merging all instantiations",
+	)
+	fset.BoolVar(
+		&opts.normalizeWS, "normalize-ws", false,
+		"Replace runs of whitespace in the frames with a single space, also when matching them",
+	)
+	fset.BoolVar(&opts.summaryLine, "summary-line", false, "Write a one line, machine readable summary to STDERR")
+	fset.StringVar(
+		&opts.summaryOut, "summary-out", "",
+		"Also write the totals and the 5 biggest allocations to `file` as JSON, whatever the output is",
+	)
+	fset.StringVar(
+		&opts.footer, "footer", "",
+		"Write at the end of the output the text/`template` given, with the fields .File, .Mode, .Cmd, .PID, "+
+			".TotalBytes, .TotalBlocks, .ShownBytes, .ShownBlocks, .Sites, .Shown and .Ignored",
+	)
+	fset.StringVar(
+		&opts.timeUnit, "time-unit", "",
+		"Display time values using the given `label` instead of the file's unit",
+	)
+	fset.IntVar(&opts.top, "top", 0, "Show only the first `N` allocations, after sorting")
+	fset.BoolVar(
+		&opts.dumpFrames, "dump-frames", false,
+		"Print every distinct frame, with the number of allocations which contain it, instead of the report",
+	)
+	fset.IntVar(
+		&opts.frameMaxLen, "frame-maxlen", 0,
+		"Truncate the displayed frames to at most `N` characters",
+	)
+	fset.IntVar(
+		&opts.groupDepth, "group-depth", 0,
+		"Aggregate the allocations which share their outermost `N` frames",
+	)
+	fset.BoolVar(&opts.dot, "dot", false, "Generate a Graphviz call graph of the allocations")
+	fset.BoolVar(&opts.redact, "redact", false, "Hide the command and PID of the profiled program")
+	fset.StringVar(
+		&opts.liveAt, "live-at", "",
+		"Show only the allocations with bytes still live at the given `time` (gmax or end)",
+	)
+	fset.StringVar(
+		&opts.filterCmd, "filter-cmd", "",
+		"Show only the allocations for which the shell `command` exits with status 0",
+	)
+	fset.BoolVar(&opts.histogram, "histogram", false, "Print the distribution of the allocation sizes")
+	fset.BoolVar(&opts.depthHist, "depth-histogram", false, "Print the distribution of the stack depths")
+	fset.BoolVar(
+		&opts.stableIDs, "stable-ids", false,
+		"Print an id derived from the frame stack of each allocation, which is the same across runs",
+	)
+	fset.BoolVar(
+		&opts.stableOrd, "stable-order", false,
+		"With -html, order the allocations by the hash of their stack and give them IDs from it,"+
+			" so regenerated reports diff minimally; -html-sortable still sorts them in the browser",
+	)
+	fset.BoolVar(&opts.watch, "watch", false, "Print the report again every time the DHAT file changes")
+	fset.IntVar(
+		&opts.concurrency, "concurrency", 1,
+		"Resolve, filter and group the allocations of big reports on `N` goroutines; 0 is one per CPU",
+	)
+	fset.Float64Var(
+		&opts.dominant, "dominant", 0,
+		"Print only the leaf frames whose allocations have more than `P` percent of the bytes (hide malloc with -hide-frame)",
+	)
+	fset.BoolVar(
+		&opts.mdTable, "md-table", false,
+		"Print only a Markdown table of the first allocations (10 or -top) and their totals, e.g. for PR comments",
+	)
+	fset.StringVar(
+		&opts.symbolMap, "symbol-map", "",
+		"Rename the frames with the `file` of \"from<TAB>to\" lines, from being an address or a name in the symbols;"+
+			" addresses take precedence over -binary, names also apply to what it resolves",
+	)
+	fset.BoolVar(
+		&opts.flagLeaks, "flag-leaks", false,
+		fmt.Sprintf(
+			"Tag the allocations still holding %.0f%% of their maximum bytes at t-end with %s and show them first",
+			likelyLeakRetention*100, likelyLeak,
+		),
+	)
+	fset.BoolVar(
+		&opts.origIndex, "orig-index", false,
+		"Number the allocations by their index in the pps array of the DHAT file, instead of their position",
+	)
+	fset.IntVar(
+		&opts.maxFrameOcc, "max-frame-occurrences", 0,
+		"Print each frame at most `N` times in the whole output, and \""+sameAsEarlier+"\" after (default unlimited)",
+	)
+	fset.BoolVar(
+		&opts.hotAccess, "hot-access-frames", false,
+		"Print the frames by the reads and writes of all the blocks allocated under them, the most accessed first",
+	)
+	fset.BoolVar(
+		&opts.tui, "tui", false,
+		"Browse the allocations in the terminal: a scrollable list, the stack of the selected one, "+
+			"and keys to sort them and filter them as you type (? lists them)",
+	)
+	fset.BoolVar(&opts.compact, "compact", false, "Print one line per allocation, showing only its leaf frame")
+	fset.BoolVar(&opts.unused, "unused", false, "Show only allocations which are never read or written")
+	fset.Float64Var(
+		&opts.topPercent, "top-percent", 0,
+		"Show only the biggest allocations which together account for `P` percent of the bytes",
+	)
+	fset.IntVar(
+		&opts.showUntil, "show-until", 0,
+		"Show the allocations, in the sort order, until they add up to more than `N` bytes",
+	)
+	fset.Var(
+		&opts.hideFrames, "hide-frame",
+		"Don't display the frames matching the `regexp`, can be repeated",
+	)
+	fset.BoolVar(
+		&opts.debugIdx, "debug-indices", false,
+		"Print the index of each allocation in the DHAT file and the indexes of its frames",
+	)
+	fset.IntVar(
+		&opts.maxPPs, "max-pps", 0,
+		"Refuse to process DHAT files with more than `N` allocations (default unlimited)",
+	)
+	fset.IntVar(&opts.minBytes, "min-bytes", 0, "Show only allocations of at least `N` bytes")
+	fset.IntVar(&opts.minBlocks, "min-blocks", 0, "Show only allocations of at least `N` blocks")
+	fset.BoolVar(&opts.sarif, "sarif", false, "Generate a SARIF log with one result per allocation")
+	fset.Var(
+		&opts.trimPrefix, "trim-prefix",
+		"Remove the `path` prefix from the file paths of the displayed frames, can be repeated",
+	)
+	fset.BoolVar(&opts.pager, "pager", false, "Write the output to $PAGER(less by default) if STDOUT is a terminal")
+	fset.BoolVar(
+		&opts.htmlFrames, "html-frames", false,
+		"Start the HTML output with a sortable table of all frames, with their allocations and bytes",
+	)
+	fset.BoolVar(
+		&opts.htmlSort, "html-sortable", false,
+		"Add buttons to the HTML output which sort the allocations by bytes or blocks in the browser",
+	)
+	fset.BoolVar(
+		&opts.htmlFrag, "html-fragment", false,
+		"Generate only a HTML table of the allocations, with their leaf frame, to be embedded in other pages",
+	)
+	fset.BoolVar(&opts.json, "json", false, "Generate JSON output, an array with one object per allocation")
+	fset.BoolVar(
+		&opts.jsonEnv, "json-envelope", false,
+		"Wrap the -json array in an object with the command, PID and mode of the report, as its \"allocations\"",
+	)
+	fset.StringVar(
+		&opts.jsonFieldsL, "json-fields", "",
+		"Comma separated `list` of the fields of the JSON objects (default all of: "+jsonFieldNames()+")",
+	)
+	fset.StringVar(
+		&opts.jsonFrames, "json-frames", "flat",
+		"How the JSON frames are written: flat as strings, or structured as objects with their function, file"+
+			" and line, or the raw frame if it has no source location",
+	)
+	fset.StringVar(
+		&opts.thousands, "thousands", "",
+		"Group the digits of the byte and block counts in thousands separated by `sep`, e.g. ','",
+	)
+	fset.BoolVar(
+		&opts.dryRun, "dry-run", false,
+		"Print for each allocation whether it would be shown or which filter drops it, instead of the report",
+	)
+	fset.BoolVar(&opts.hexBytes, "hex-bytes", false, "Print the byte counts in hexadecimal")
+	fset.BoolVar(
+		&opts.diffSites, "diff-sites", false,
+		"With -base, print only the allocation sites which were added or removed, ignoring changes of their bytes",
+	)
+	fset.StringVar(
+		&opts.sanitize, "sanitize-utf8", "",
+		"Show invalid UTF-8 in the displayed frames according to `mode`: replace(with U+FFFD), hex(as \\xNN)"+
+			" or off (default replace for HTML, off otherwise)",
+	)
+	fset.BoolVar(
+		&opts.escapeCtl, "escape-control", false,
+		"Show the tabs, newlines and other non-printable characters of the displayed frames escaped, like \\t",
+	)
+	fset.IntVar(
+		&opts.maxPerLeaf, "max-per-leaf", 0,
+		"Show at most `N` allocations with the same leaf frame, the biggest ones (hide allocator frames with -hide-frame)",
+	)
+	fset.StringVar(
+		&opts.binary, "binary", "",
+		"Resolve the frames which DHAT left as bare addresses with addr2line, using the profiled `program`",
+	)
+	fset.StringVar(
+		&opts.buildID, "build-id", "",
+		"Warn if the -binary doesn't have this build-`id`, instead of comparing it to the program in the command of DHAT",
+	)
+	fset.BoolVar(
+		&opts.listUnres, "list-unresolved", false,
+		"Print only the addresses of the frames which neither DHAT nor -binary resolved, one per line",
+	)
+	fset.BoolVar(
+		&opts.noTimestamp, "no-timestamp", false,
+		"Don't print when the report was generated, so the output of the same DHAT file is always the same",
+	)
+	fset.BoolVar(&opts.stripArgs, "strip-args", false, "Don't display the parameter lists of the functions in the frames")
+	fset.BoolVar(
+		&opts.partialOK, "partial-ok", false,
+		"Accept a truncated DHAT file, e.g. of a killed run, using the allocations read before its end",
+	)
+	fset.IntVar(
+		&opts.diffMin, "diff-min", 0,
+		"With -base, print only the allocation sites whose bytes changed by more than `N`",
+	)
+	fset.BoolVar(&opts.byThread, "by-thread", false, "Aggregate the allocations by the thread found in their frames")
+	fset.StringVar(
+		&opts.threadExpr, "thread-re", `(?i)\bthread[ #:_-]*(\w+)`,
+		"The `regexp` which finds the thread in the frames for -by-thread, its last group being the thread",
+	)
+	fset.StringVar(
+		&opts.modules, "modules", "",
+		"`File` mapping globs of source files to module names for -by-module, one \"glob module\" per line",
+	)
+	fset.BoolVar(
+		&opts.byModule, "by-module", false,
+		"Aggregate the allocations by the -modules of the source file of their innermost frame with one",
+	)
+	fset.StringVar(
+		&opts.structFile, "struct-map", "",
+		"`File` with the field offsets of the blocks of given sizes, to show their accesses by field",
+	)
+	fset.BoolVar(
+		&opts.foldInlined, "fold-inlined", false,
+		"Display consecutive frames with the same source location, like inlined functions, as one",
+	)
+	fset.BoolVar(
+		&opts.anyVersion, "force-version", false,
+		"Process DHAT files of unsupported versions anyway, whose output may be wrong or incomplete",
+	)
+	fset.IntVar(&opts.sample, "sample", 0, "Show only `N` allocations chosen at random")
+	fset.Int64Var(
+		&opts.seed, "seed", 0,
+		"The `seed` of the random choice of -sample, to choose the same allocations again (default a new one)",
+	)
+	fset.StringVar(
+		&opts.annotate, "annotate", "",
+		"Print the notes in `file` for the allocations, each line being a -stable-ids id followed by its note",
+	)
+	fset.BoolVar(
+		&opts.nonzeroEnd, "nonzero-end", false,
+		"Same as -live-at end, showing only the allocations with bytes left at t-end, i.e. leaks, "+
+			"and their bytes at t-end, best combined with -sort end",
+	)
+	fset.BoolVar(&opts.diffJSON, "diff-json", false, "With -base, write the changed allocation sites as JSON")
+	fset.BoolVar(
+		&opts.trimCommon, "trim-common", false,
+		"Don't display the outermost frames shared by all allocations, printing them once in the header",
+	)
+	fset.StringVar(
+		&opts.sep, "sep", "",
+		"Start each allocation of the text report with the line `format`, where %d is replaced by its number"+
+			" (default \"==== Allocation #%d ====\")",
+	)
+	fset.BoolVar(
+		&opts.splitCmd, "split-cmd", false,
+		"Print the program and each of its arguments on its own line, instead of the whole command",
+	)
+	fset.BoolVar(
+		&opts.heat, "heat", false,
+		"Color the bytes of each allocation from green to red by their size, if STDOUT is a color terminal",
+	)
+	fset.BoolVar(&opts.explainAll, "explain", false, "Explain the meaning of each field of the report next to it")
+	fset.StringVar(
+		&opts.trend, "trend", "",
+		"Print the bytes of each allocation site in the comma separated `list` of older DHAT files and the given one",
+	)
+	fset.BoolVar(
+		&opts.byStack, "by-stack", false,
+		"Aggregate the allocations with the same resolved frame stack, printing each stack once",
+	)
+	fset.BoolVar(&opts.crlf, "crlf", false, "End the lines of the output with CRLF, for Windows tools")
+	fset.BoolVar(&opts.bom, "bom", false, "Start the output with a UTF-8 byte order mark, for Windows tools")
+	fset.Var(
+		&opts.baselines, "base",
+		"Compare against the baseline DHAT `file`, can be repeated to compare against the average of many",
+	)
 
 	if err := fset.Parse(args); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -61,16 +898,31 @@ func mainErr(args []string) error {
 		return err
 	}
 
+	if *configFile != "" {
+		if err := applyConfigFile(fset, *configFile); err != nil {
+			return err
+		}
+	}
+
 	if *printVersion {
 		version()
 		return nil
 	}
 
+	if *printSchemaOnly {
+		printSchema(os.Stdout)
+		return nil
+	}
+
 	if fset.NArg() != 1 {
 		fset.Usage()
 		return fmt.Errorf("need DHAT file")
 	}
 
+	if *memLimit > 0 {
+		debug.SetMemoryLimit(*memLimit)
+	}
+
 	if *cpuProfile {
 		f, err := os.Create("profile.cpu")
 		if err != nil {
@@ -79,8 +931,22 @@ func mainErr(args []string) error {
 		_ = pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return err
+		}
+		defer func() {
+			trace.Stop()
+			f.Close()
+		}()
+	}
 	defer func() {
-		if *memProfile {
+		if opts.memStats {
 			f, err := os.Create("profile.mem")
 			if err != nil {
 				fmt.Fprintln(os.Stderr, "error:", err)
@@ -90,77 +956,418 @@ func mainErr(args []string) error {
 		}
 	}()
 
-	ignoreList, err := parseIgnoreFile(*ignoreFile)
+	if err := opts.init(); err != nil {
+		return err
+	}
+	opts.colors = opts.heat && useColors(&opts)
+
+	if opts.watch {
+		return watch(fset.Arg(0), &opts)
+	}
+
+	if opts.pager {
+		return withPager(func(w io.Writer) error {
+			return run(w, fset.Arg(0), &opts)
+		})
+	}
+
+	return run(os.Stdout, fset.Arg(0), &opts)
+}
+
+// run loads the given DHAT file and writes the output selected by the flags.
+func run(w io.Writer, file string, opts *options) error {
+	report, err := opts.loadReport(file)
 	if err != nil {
 		return err
 	}
 
-	report, err := parseReport(fset.Arg(0))
+	if opts.memStats {
+		printMemStats(os.Stderr, report)
+	}
+
+	// With -watch, every report counts the printed frames again.
+	opts.frameSeen = nil
+
+	if opts.bom {
+		if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+			return err
+		}
+	}
+	if opts.crlf {
+		w = &crlfWriter{w: w}
+	}
+
+	if opts.sortKey.needsAccesses && !report.BlockAccessesRecorded {
+		return fmt.Errorf("cannot sort by %s, the DHAT report has no block accesses recorded", opts.sortBy)
+	}
+	if opts.sortKey.needsLifetimes && !report.BlockLifetimesRecorded {
+		return fmt.Errorf("cannot sort by %s, the DHAT report has no block lifetimes recorded", opts.sortBy)
+	}
+
+	if (opts.rwRatio || opts.writeOnly || opts.unused) && !report.BlockAccessesRecorded {
+		return fmt.Errorf("-rw-ratio, -write-only and -unused need a DHAT report with block accesses recorded")
+	}
+	if opts.flagLeaks && !report.BlockLifetimesRecorded {
+		return fmt.Errorf("-flag-leaks needs a DHAT report with block lifetimes recorded")
+	}
+	if opts.hotAccess && !report.BlockAccessesRecorded {
+		return fmt.Errorf("-hot-access-frames needs a DHAT report with block accesses recorded")
+	}
+
+	if opts.liveAt != "" && !report.BlockLifetimesRecorded {
+		return fmt.Errorf("-live-at and -nonzero-end need a DHAT report with block lifetimes recorded")
+	}
+
+	if opts.timeUnit != "" {
+		report.TimeUnit = opts.timeUnit
+	}
+
+	// Copy mode is about finding the sites which copy the most, which is
+	// what the report shows first unless another order is asked for.
+	if opts.sortBy == "" && report.InvocationMode == "copy" {
+		opts.sortKey = sortKeys["bytes"]
+	}
+
+	if opts.dryRun {
+		return printDryRun(w, report, opts)
+	}
+
+	if opts.listUnres {
+		printUnresolved(w, report)
+		return nil
+	}
+
+	pps, err := opts.selectProgramPoints(report)
 	if err != nil {
 		return err
 	}
 
-	const dhatVersion = 2
-	if report.Version != dhatVersion {
-		return fmt.Errorf(
-			"DHAT report version %d is not supported, only version %d is supported",
-			report.Version, dhatVersion,
+	if opts.sortKey.cmp != nil {
+		sortProgramPoints(report, pps, opts.sortKey, opts.sortAsc)
+	}
+	if opts.flagLeaks {
+		leaksFirst(report, pps)
+	}
+
+	switch {
+	case opts.tui:
+		if err := runTUI(report, pps, opts); err != nil {
+			return err
+		}
+	case opts.hotAccess:
+		frames := hotAccessFrames(report, pps, opts)
+		if opts.top > 0 && len(frames) > opts.top {
+			frames = frames[:opts.top]
+		}
+		printHotAccessFrames(w, report, frames, opts)
+	case opts.dumpFrames:
+		printFrames(w, report, pps)
+	case opts.sarif:
+		if err := printSarif(w, report, pps, opts); err != nil {
+			return err
+		}
+	case opts.histogram:
+		printHistogram(w, report, "Size", sizeBins(report, pps), opts)
+	case opts.depthHist:
+		printHistogram(w, report, "Depth", depthBins(report, pps), opts)
+	case opts.dot:
+		printDot(w, report, pps, opts)
+	case opts.dominant > 0:
+		leaves, total := dominantLeaves(report, pps, opts.dominant, opts)
+		printDominant(w, report, leaves, total, opts.dominant, opts)
+	case opts.mdTable:
+		printMarkdownTable(w, report, pps, opts)
+	case opts.byThread:
+		groups := groupByThread(report, pps, opts)
+		if opts.top > 0 && len(groups) > opts.top {
+			groups = groups[:opts.top]
+		}
+		printGroups(w, report, groups, "allocations", opts)
+	case opts.byModule:
+		groups := groupByLabel(report, pps, "module", func(i int) string { return opts.moduleOf(report, i) })
+		if opts.top > 0 && len(groups) > opts.top {
+			groups = groups[:opts.top]
+		}
+		printGroups(w, report, groups, "allocations", opts)
+	case opts.byStack:
+		groups := groupProgramPoints(report, pps, report.StackKeySymbols)
+		if opts.top > 0 && len(groups) > opts.top {
+			groups = groups[:opts.top]
+		}
+		printGroups(w, report, groups, "instances", opts)
+	case opts.groupDepth > 0:
+		groups := groupProgramPoints(report, pps, func(i int) []int {
+			stack := report.StackKeySymbols(i)
+			return stack[:min(len(stack), opts.groupDepth)]
+		})
+		if opts.top > 0 && len(groups) > opts.top {
+			groups = groups[:opts.top]
+		}
+		printGroups(w, report, groups, "allocations", opts)
+	case len(opts.trendFiles) != 0:
+		if err := runTrend(w, file, report, pps, opts); err != nil {
+			return err
+		}
+	case len(opts.baselines) != 0:
+		if err := runDiff(w, report, pps, opts); err != nil {
+			return err
+		}
+	default:
+		var notes []string
+		if opts.sample > 0 {
+			var note string
+			pps, note = samplePPs(pps, opts.sample, opts.seed)
+			notes = append(notes, note)
+		}
+		if opts.maxPerLeaf > 0 {
+			var leafNotes []string
+			pps, leafNotes = capPerLeaf(report, pps, opts.maxPerLeaf, opts)
+			notes = append(notes, leafNotes...)
+		}
+		if opts.top > 0 && len(pps) > opts.top {
+			pps = pps[:opts.top]
+		}
+		if opts.topPercent > 0 {
+			var note string
+			pps, note = topPercent(report, pps, opts.topPercent)
+			notes = append(notes, note)
+		}
+		if opts.showUntil > 0 {
+			var note string
+			if pps, note = showUntil(report, pps, opts.showUntil, opts); note != "" {
+				notes = append(notes, note)
+			}
+		}
+		switch {
+		case opts.json:
+			if err := printJSON(w, report, pps, opts); err != nil {
+				return err
+			}
+		case opts.htmlFrag:
+			printHTMLFragment(w, report, pps, opts)
+		case opts.compact:
+			printCompact(w, report, pps, notes, opts)
+		default:
+			if err := printReport(w, report, pps, notes, opts); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The program points shown are only known once the output has chosen
+	// them, e.g. with -top.
+	if opts.summaryOut != "" {
+		if err := writeSummary(file, report, pps, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.footerTmpl != nil {
+		if err := printFooter(w, file, report, pps, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.summaryLine {
+		shownBytes := 0
+		for _, i := range pps {
+			shownBytes += report.ProgramPoints[i].TotalBytes
+		}
+		fmt.Fprintf(
+			os.Stderr, "dhatless: pps=%d shown=%d ignored=%d bytes=%d\n",
+			len(report.ProgramPoints), len(pps), len(report.ProgramPoints)-len(pps), shownBytes,
 		)
 	}
 
-	if *outputHtml {
-		fmt.Print(htmlHeader)
+	return nil
+}
+
+// printReport writes the header of the report followed by the program points
+// pps, in the given order, and the notes about how they were selected.
+func printReport(w io.Writer, report *Report, pps []int, notes []string, opts *options) error {
+	if opts.html {
+		fmt.Fprint(w, htmlHeader)
 	}
 
-	if *outputHtml {
-		fmt.Printf("<br><pre>\n")
+	if opts.html {
+		fmt.Fprintf(w, "<br><pre>\n")
 	}
 
-	fmt.Printf("Command: %s\n", report.Cmd)
-	fmt.Printf("PID: %d\n", report.PID)
-	fmt.Printf("Mode: %s\n", report.InvocationMode)
-	fmt.Printf("t-end: %d %s\n", report.TimeAtEnd, report.TimeUnit)
+	printHeader(w, report, opts)
 
-	if *outputHtml {
-		fmt.Printf("</pre><br><hr><br>\n")
+	var elemIDs []string
+	if opts.stableOrd {
+		pps, elemIDs = stableOrder(report, pps)
 	}
 
-	allocCount := 1
+	stacks := make([][]string, len(pps))
+	for n, i := range pps {
+		stacks[n] = opts.visibleStack(report, report.ProgramPoints[i])
+	}
+	common := 0
+	if opts.trimCommon {
+		common = commonPrefixLen(stacks)
+	}
+	if common > 0 {
+		fmt.Fprintln(w, "Common prefix:")
+		for _, frame := range opts.displayStack(stacks[0][:common]) {
+			if opts.html {
+				frame = html.EscapeString(frame)
+			}
+			fmt.Fprintf(w, "  %s\n", frame)
+		}
+	}
 
-	for i, pp := range report.ProgramPoints {
-		if shouldIgnore(*report, i, ignoreList) {
-			continue
+	if opts.html {
+		fmt.Fprintf(w, "</pre><br><hr><br>\n")
+		if opts.htmlFrames {
+			printFramesTable(w, report, pps, opts)
 		}
+		if opts.htmlSort {
+			printSortButtons(w)
+			fmt.Fprintln(w, `<div id="allocations">`)
+		}
+	}
 
-		if *outputHtml {
-			fmt.Printf("<details><summary>Allocation #%d</summary><br><p>\n", allocCount)
-		} else {
-			fmt.Printf("\n==== Allocation #%d ====\n", allocCount)
+	maxBytes := 0
+	if opts.colors {
+		for _, i := range pps {
+			maxBytes = max(maxBytes, report.ProgramPoints[i].TotalBytes)
 		}
+	}
 
-		fmt.Printf("%d bytes in %d blocks\n", pp.TotalBytes, pp.TotalBlocks)
+	for n, i := range pps {
+		pp := report.ProgramPoints[i]
+		allocCount := n + 1
 
-		allocCount++
+		var info string
+		if opts.stableIDs {
+			info += " (id " + report.StackID(i) + ")"
+		}
+		if opts.debugIdx {
+			fs := make([]string, len(pp.Frames))
+			for j, frame := range pp.Frames {
+				fs[j] = strconv.Itoa(frame)
+			}
+			info += fmt.Sprintf(" [pp #%d, fs=[%s]]", i, strings.Join(fs, ","))
+		}
+		if opts.flagLeaks && isLikelyLeak(pp) {
+			info += " " + likelyLeak
+		}
+		title := fmt.Sprintf("Allocation #%d", opts.allocNumber(allocCount, i)) + info
+
+		if opts.html {
+			if opts.htmlSort {
+				fmt.Fprintf(
+					w, `<div data-order="%d" data-bytes="%d" data-blocks="%d">`,
+					allocCount, pp.TotalBytes, pp.TotalBlocks,
+				)
+			}
+			id := ""
+			if elemIDs != nil {
+				id = fmt.Sprintf(` id="%s"`, elemIDs[n])
+			}
+			fmt.Fprintf(w, "<details%s><summary>%s</summary><br><p>\n", id, title)
+		} else if opts.sep != "" {
+			fmt.Fprintf(w, "\n%s%s\n", strings.ReplaceAll(opts.sep, "%d", strconv.Itoa(opts.allocNumber(allocCount, i))), info)
+		} else {
+			fmt.Fprintf(w, "\n==== %s ====\n", title)
+		}
+
+		bytes := opts.bytes(pp.TotalBytes)
+		if opts.colors {
+			bytes = heatColor(bytes, pp.TotalBytes, maxBytes)
+		}
+		fmt.Fprintf(
+			w, "%s %s in %s %s%s\n",
+			bytes, report.BytesLabel(), opts.count(pp.TotalBlocks), report.BlocksLabel(), opts.explain("bytes"),
+		)
+		if opts.sortKey.needsAccesses {
+			fmt.Fprintf(w, "%d reads, %d writes%s\n", pp.ReadsOfBlocks, pp.WritesOfBlocks, opts.explain("accesses"))
+		}
+		if opts.sortKey.needsLifetimes || opts.nonzeroEnd {
+			fmt.Fprintf(w, "%s %s at t-end%s\n", opts.bytes(pp.BytesAtTend), report.BytesLabel(), opts.explain("at t-end"))
+		}
+		if opts.rwRatio {
+			fmt.Fprintf(w, "r/w: %s%s\n", readWriteRatio(pp), opts.explain("r/w"))
+		}
+		if opts.flagLeaks {
+			fmt.Fprintf(w, "Retention: %.0f%%%s\n", retention(pp)*100, opts.explain("Retention"))
+		}
+		if note, ok := opts.siteNotes[report.StackID(i)]; ok {
+			if opts.html {
+				note = html.EscapeString(note)
+			}
+			fmt.Fprintf(w, "Annotation: %s%s\n", note, opts.explain("Annotation"))
+		}
+		if len(opts.structMap) != 0 && len(pp.BlockAccesses) != 0 {
+			counts := decodeAccesses(pp.BlockAccesses)
+			if layout := opts.structLayout(len(counts)); layout != nil {
+				fields := hotFields(layout, counts)
+				if opts.html {
+					fields = html.EscapeString(fields)
+				}
+				fmt.Fprintf(w, "Field accesses: %s%s\n", fields, opts.explain("Field accesses"))
+			}
+		}
 
-		if *outputHtml {
-			fmt.Println("</p><pre>")
+		if opts.html {
+			fmt.Fprintln(w, "</p><pre>")
 		}
 
-		for j := len(pp.Frames) - 1; j >= 0; j-- {
-			frame := report.GetFrame(pp.Frames[j])
-			if *outputHtml {
+		for _, frame := range opts.limitRepeats(opts.displayStack(stacks[n][common:])) {
+			if opts.html {
 				frame = html.EscapeString(frame)
 			}
-			fmt.Printf("%s\n", frame)
+			fmt.Fprintf(w, "%s\n", frame)
 		}
 
-		if *outputHtml {
-			fmt.Println("</pre></details><br>")
+		if opts.html {
+			fmt.Fprintln(w, "</pre>")
+			if report.BlockAccessesRecorded && len(pp.BlockAccesses) != 0 {
+				counts := decodeAccesses(pp.BlockAccesses)
+				printHeatmap(w, counts, opts.structLayout(len(counts)))
+			}
+			if opts.htmlRaw {
+				raw, err := json.MarshalIndent(pp, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(
+					w, "<details><summary>Raw JSON</summary><pre>\n%s\n</pre></details>\n",
+					html.EscapeString(string(raw)),
+				)
+			}
+			fmt.Fprintln(w, "</details><br>")
+			if opts.htmlSort {
+				fmt.Fprintln(w, "</div>")
+			}
 		}
 	}
 
-	if *outputHtml {
-		fmt.Print(`
+	if opts.html && opts.htmlSort {
+		fmt.Fprintln(w, "</div>")
+	}
+
+	if len(notes) != 0 {
+		if opts.html {
+			fmt.Fprintln(w, "<hr><pre>")
+		} else {
+			fmt.Fprintln(w)
+		}
+		for _, note := range notes {
+			if opts.html {
+				note = html.EscapeString(note)
+			}
+			fmt.Fprintln(w, note)
+		}
+		if opts.html {
+			fmt.Fprintln(w, "</pre>")
+		}
+	}
+
+	if opts.html {
+		fmt.Fprint(w, `
 </body>
 </html>
 `)
@@ -169,6 +1376,114 @@ func mainErr(args []string) error {
 	return nil
 }
 
+// printHeader writes the details of the profiled program found in report.
+func printHeader(w io.Writer, report *Report, opts *options) {
+	cmd, pid := report.Cmd, strconv.Itoa(report.PID)
+	if opts.redact {
+		cmd, pid = redacted, redacted
+	}
+	if opts.html {
+		cmd = html.EscapeString(cmd)
+		pid = html.EscapeString(pid)
+	}
+
+	if opts.splitCmd && !opts.redact {
+		program, args := splitCommand(report.Cmd)
+		if opts.html {
+			program = html.EscapeString(program)
+		}
+		fmt.Fprintf(w, "Program: %s%s\n", program, opts.explain("Command"))
+		for n, arg := range args {
+			label := "Args:"
+			if n != 0 {
+				label = ""
+			}
+			if arg == "" {
+				arg = "''"
+			}
+			if opts.html {
+				arg = html.EscapeString(arg)
+			}
+			fmt.Fprintf(w, "%-9s%s\n", label, arg)
+		}
+	} else {
+		fmt.Fprintf(w, "Command: %s%s\n", cmd, opts.explain("Command"))
+	}
+	fmt.Fprintf(w, "PID: %s%s\n", pid, opts.explain("PID"))
+	fmt.Fprintf(w, "Mode: %s%s\n", report.InvocationMode, opts.explain("Mode"))
+	if note, ok := modeNotes[report.InvocationMode]; ok {
+		fmt.Fprintf(w, "Note: %s\n", note)
+	}
+	fmt.Fprintf(w, "t-end: %d %s%s\n", report.TimeAtEnd, report.TimeUnit, opts.explain("t-end"))
+	if !opts.noTimestamp {
+		fmt.Fprintf(w, "Generated: %s%s\n", time.Now().Format(time.RFC3339), opts.explain("Generated"))
+	}
+}
+
+// modeNotes explains what is counted in each of the DHAT invocation modes.
+var modeNotes = map[string]string{
+	"heap":   "bytes and blocks are the heap memory allocated at each site",
+	"copy":   "bytes are the memory copied at each site by memcpy, strcpy and alike, blocks are the copies done",
+	"ad-hoc": "the counts are the units and events recorded at each site with DHAT_AD_HOC_EVENT",
+}
+
+// redacted replaces the details hidden by -redact.
+const redacted = "<redacted>"
+
+// applyConfigFile sets the flags of fset found in the given config file,
+// except the ones already given on the command line.
+func applyConfigFile(fset *flag.FlagSet, file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	explicit := make(map[string]bool)
+	fset.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for n, line := range strings.Split(string(content), "\n") {
+		line := strings.Trim(line, " \t")
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected key=value", file, n+1)
+		}
+		name = strings.Trim(name, " \t")
+		value = strings.Trim(value, " \t")
+		if name == "config" {
+			return fmt.Errorf("%s:%d: config files can't include other config files", file, n+1)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := fset.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: %w", file, n+1, err)
+		}
+	}
+
+	return nil
+}
+
+// stringList is a flag.Value which collects the values of a flag given
+// multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
 func version() {
 	bi, _ := debug.ReadBuildInfo()
 	g := func(k string) string {
@@ -231,6 +1546,38 @@ details[open] > summary {
   background-color: #ccf;
 }
 
+.heatmap {
+  border-collapse: collapse;
+  margin: 6px 0;
+}
+
+.heatmap th {
+  font-weight: normal;
+  padding-right: 6px;
+  text-align: right;
+}
+
+.heatmap td {
+  width: 12px;
+  height: 12px;
+  border: 1px solid #ccc;
+}
+
+#frames-table {
+  border-collapse: collapse;
+}
+
+#frames-table th {
+  background-color: #ddd;
+  cursor: pointer;
+}
+
+#frames-table th, #frames-table td {
+  border: 1px solid #ccc;
+  padding: 2px 6px;
+  text-align: left;
+}
+
 button {
   background-color: #ddd;
   font-size: 15px;
@@ -269,31 +1616,112 @@ document.getElementById("btn-closeall").addEventListener("click", function(event
 
 `
 
-func parseReport(file string) (*Report, error) {
-	f, err := os.Open(file)
+// parseReport reads the DHAT file. If partialOK is set, a truncated file is
+// accepted, keeping the allocations read before the end of the file.
+func parseReport(file string, partialOK bool, workers int) (*Report, error) {
+	f, err := openReport(file)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
+
+	// A DHAT file is a JSON object, anything else is most likely the wrong
+	// file, for which the errors of the decoder would be confusing.
+	br := bufio.NewReader(f)
+	var skipped int64
+	for {
+		c, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s is empty (expected a DHAT report)", file)
+		} else if err != nil {
+			return nil, err
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			skipped++
+			continue
+		}
+		if c != '{' {
+			return nil, fmt.Errorf("%s is not a JSON file (expected a DHAT report)", file)
+		}
+		_ = br.UnreadByte()
+		break
+	}
+
+	dec := json.NewDecoder(br)
+	decode := func(r *Report) (bool, error) {
+		if partialOK {
+			truncated, err := decodePartial(dec, r)
+			return truncated, syntaxError(file, skipped, err)
+		}
+		return false, syntaxError(file, skipped, dec.Decode(r))
+	}
+
+	// Some tools concatenate several DHAT runs in one file, in which case
+	// all of them are merged in the first one.
 	var report Report
-	if err := json.NewDecoder(f).Decode(&report); err != nil {
+	truncated, err := decode(&report)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%s is truncated, use -partial-ok to read it anyway: %w", file, err)
+	} else if err != nil {
 		return nil, err
 	}
+	for n := 2; !truncated && dec.More(); n++ {
+		var next Report
+		if truncated, err = decode(&next); err != nil {
+			return nil, fmt.Errorf("object #%d: %w", n, err)
+		}
+		if err := report.merge(&next); err != nil {
+			return nil, fmt.Errorf("object #%d: %w", n, err)
+		}
+	}
+	if truncated {
+		fmt.Fprintf(
+			os.Stderr, "warning: %s is truncated, only the %d allocations read before its end are used\n",
+			file, len(report.ProgramPoints),
+		)
+	}
+	report.workers = workers
+	report.resolveFrames()
 	return &report, nil
 }
 
-func parseIgnoreFile(file string) ([]string, error) {
+// downloadTimeout is how long the download of a DHAT report given as a URL
+// can take, including reading it, before it is given up.
+const downloadTimeout = 5 * time.Minute
+
+// openReport opens the given DHAT file, which can also be a HTTP(S) URL from
+// which the report is downloaded.
+func openReport(file string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(file, "http://") && !strings.HasPrefix(file, "https://") {
+		return os.Open(file)
+	}
+	client := &http.Client{Timeout: downloadTimeout}
+	rsp, err := client.Get(file)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", file, rsp.Status)
+	}
+	return rsp.Body, nil
+}
+
+func parseIgnoreFile(file string) ([]string, []string, error) {
 	if file == "" {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	ignoreList := make([]string, 0, 32)
+	var keepList []string
 
 	content, err := os.ReadFile(file)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	list := &ignoreList
+
 	for _, line := range strings.Split(string(content), "\n") {
 		line := strings.Trim(line, " \t")
 		if line == "" {
@@ -302,20 +1730,88 @@ func parseIgnoreFile(file string) ([]string, error) {
 		if line[0] == '#' {
 			continue
 		}
-		ignoreList = append(ignoreList, line)
+		switch line {
+		case "[ignore]":
+			list = &ignoreList
+			continue
+		case "[keep]":
+			list = &keepList
+			continue
+		}
+		*list = append(*list, line)
 	}
 
-	return ignoreList, nil
+	return ignoreList, keepList, nil
 
 }
 
-func shouldIgnore(r Report, frame int, ignoreList []string) bool {
-	for _, s := range ignoreList {
-		if r.ProgramPointHasFrame(frame, s) {
-			return true
+// parseIgnoreRegexFile returns the regular expressions in file, one per line,
+// skipping the empty lines and the comments.
+func parseIgnoreRegexFile(file string) ([]*regexp.Regexp, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*regexp.Regexp
+	for n, line := range strings.Split(string(content), "\n") {
+		line := strings.Trim(line, " \t\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, n+1, err)
 		}
+		res = append(res, re)
 	}
-	return false
+	return res, nil
+}
+
+// readWriteRatio formats the ratio between the reads and writes of the blocks
+// allocated at pp, naming the cases where one of them is zero.
+func readWriteRatio(pp ProgramPoint) string {
+	switch {
+	case pp.ReadsOfBlocks == 0 && pp.WritesOfBlocks == 0:
+		return "n/a (unused)"
+	case pp.WritesOfBlocks == 0:
+		return "inf (read-only)"
+	case pp.ReadsOfBlocks == 0:
+		return "0.00 (write-only)"
+	}
+	return fmt.Sprintf("%.2f", float64(pp.ReadsOfBlocks)/float64(pp.WritesOfBlocks))
+}
+
+func hasAnyKeyword(r Report, frame int, keywords []string) bool {
+	return matchingKeyword(r, frame, keywords) != ""
+}
+
+// matchingKeyword returns the first of the keywords found in the frames of
+// the program point i, or "" if none is.
+func matchingKeyword(r Report, i int, keywords []string) string {
+	for _, s := range keywords {
+		if r.ProgramPointHasFrame(i, s) {
+			return s
+		}
+	}
+	return ""
+}
+
+// matchingRegexp returns the first of the regular expressions matching one
+// of the frames of the program point i, or nil if none does.
+func matchingRegexp(r Report, i int, res []*regexp.Regexp) *regexp.Regexp {
+	for _, re := range res {
+		for _, frame := range r.ProgramPoints[i].Frames {
+			if re.MatchString(r.GetFrame(frame)) {
+				return re
+			}
+		}
+	}
+	return nil
 }
 
 type Report struct {
@@ -371,21 +1867,264 @@ type Report struct {
 	ProgramPoints []ProgramPoint `json:"pps"`
 
 	// Frame table. A mandatory array of strings.
-	FramesTable []string `json:"ftbl"`
+	FramesTable framesTable `json:"ftbl"`
+
+	// The distinct resolved symbols of the frame table and, for each entry
+	// of the frame table, the index of its symbol. Frames with the same
+	// symbol have the same index, which is cheaper to compare and hash than
+	// the symbol itself. Set by resolveFrames.
+	symbols  []string
+	frameSym []int
+
+	// The bytes of the program points with each symbol as their leaf frame,
+	// computed by sumLeafBytes for selfBytes.
+	leafBytes []int
+
+	// The number of workers of the passes over the whole report, from
+	// -concurrency.
+	workers int
+}
+
+// resolveFrames strips the address prefix from the entries of the frame
+// table and interns the resulting symbols. With more than one worker, each
+// chunk of the frame table is interned on its own first, then the symbols of
+// the chunks are merged in order, so they get the same indexes as with one.
+func (r *Report) resolveFrames() {
+	r.frameSym = make([]int, len(r.FramesTable))
+
+	cs := chunks(len(r.FramesTable), r.workers)
+	chunkSyms := make([][]string, len(cs))
+	forEachChunk(len(r.FramesTable), r.workers, func(k int, c chunk) {
+		index := make(map[string]int, c.hi-c.lo)
+		syms := make([]string, 0, c.hi-c.lo)
+		for i := c.lo; i < c.hi; i++ {
+			// Only the first ": " separates the address from the symbol,
+			// C++ symbols can contain more of them, e.g. in lambda
+			// signatures.
+			sym := r.FramesTable[i]
+			if _, after, ok := strings.Cut(sym, ": "); ok {
+				sym = after
+			}
+			n, ok := index[sym]
+			if !ok {
+				n = len(syms)
+				index[sym] = n
+				syms = append(syms, sym)
+			}
+			r.frameSym[i] = n
+		}
+		chunkSyms[k] = syms
+	})
+
+	if len(cs) == 1 {
+		r.symbols = chunkSyms[0]
+		r.leafBytes = nil
+		return
+	}
+
+	index := make(map[string]int, len(r.FramesTable))
+	r.symbols = make([]string, 0, len(r.FramesTable))
+	for k, c := range cs {
+		global := make([]int, len(chunkSyms[k]))
+		for n, sym := range chunkSyms[k] {
+			g, ok := index[sym]
+			if !ok {
+				g = len(r.symbols)
+				index[sym] = g
+				r.symbols = append(r.symbols, sym)
+			}
+			global[n] = g
+		}
+		for i := c.lo; i < c.hi; i++ {
+			r.frameSym[i] = global[r.frameSym[i]]
+		}
+	}
+	r.leafBytes = nil
+}
+
+// merge appends the program points of other to r. Both must be recorded in
+// the same mode, with the same units. The header of r is kept, except for
+// t-end which becomes the latest of the two.
+func (r *Report) merge(other *Report) error {
+	if other.Version != r.Version {
+		return fmt.Errorf("cannot merge DHAT file version %d with version %d", other.Version, r.Version)
+	}
+	if other.InvocationMode != r.InvocationMode {
+		return fmt.Errorf("cannot merge mode %q with mode %q", other.InvocationMode, r.InvocationMode)
+	}
+	if other.BytesUnit != r.BytesUnit || other.BlocksUnit != r.BlocksUnit || other.TimeUnit != r.TimeUnit {
+		return fmt.Errorf("cannot merge reports with different units")
+	}
+
+	r.BlockLifetimesRecorded = r.BlockLifetimesRecorded && other.BlockLifetimesRecorded
+	r.BlockAccessesRecorded = r.BlockAccessesRecorded && other.BlockAccessesRecorded
+	r.TimeAtEnd = max(r.TimeAtEnd, other.TimeAtEnd)
+
+	offset := len(r.FramesTable)
+	r.FramesTable = append(r.FramesTable, other.FramesTable...)
+	for _, pp := range other.ProgramPoints {
+		for n := range pp.Frames {
+			pp.Frames[n] += offset
+		}
+		r.ProgramPoints = append(r.ProgramPoints, pp)
+	}
+	return nil
 }
 
 func (r Report) ProgramPointHasFrame(i int, s string) bool {
 	for _, frame := range r.ProgramPoints[i].Frames {
-		sym := strings.Split(r.FramesTable[frame], ": ")[1]
-		if strings.Contains(sym, s) {
+		if strings.Contains(r.GetFrame(frame), s) {
 			return true
 		}
 	}
 	return false
 }
 
+// MapFrames replaces the symbol of every entry in the frame table with the
+// result of calling f with it, leaving the address prefix untouched.
+func (r *Report) MapFrames(f func(string) string) {
+	for i, frame := range r.FramesTable {
+		addr, sym, ok := strings.Cut(frame, ": ")
+		if !ok {
+			continue
+		}
+		r.FramesTable[i] = addr + ": " + f(sym)
+	}
+	r.resolveFrames()
+}
+
+// leafFrame returns the resolved innermost frame of program point i, or "" if
+// it has no frames.
+func (r Report) leafFrame(i int) string {
+	frames := r.ProgramPoints[i].Frames
+	if len(frames) == 0 {
+		return ""
+	}
+	return r.GetFrame(frames[0])
+}
+
+// sumLeafBytes sums the bytes of the program points pps by the symbol of their
+// leaf frame, for selfBytes.
+func (r *Report) sumLeafBytes(pps []int) {
+	r.leafBytes = make([]int, len(r.symbols))
+	for _, i := range pps {
+		if pp := r.ProgramPoints[i]; len(pp.Frames) != 0 {
+			r.leafBytes[r.frameSym[pp.Frames[0]]] += pp.TotalBytes
+		}
+	}
+}
+
+// selfBytes returns the bytes allocated by the function in the leaf frame of
+// program point i, summed over the program points given to sumLeafBytes with
+// the same leaf frame.
+func (r *Report) selfBytes(i int) int {
+	frames := r.ProgramPoints[i].Frames
+	if len(frames) == 0 {
+		return r.ProgramPoints[i].TotalBytes
+	}
+	return r.leafBytes[r.frameSym[frames[0]]]
+}
+
+// accesses returns the reads and writes of the blocks of program point i.
+func (r Report) accesses(i int) int {
+	return r.ProgramPoints[i].ReadsOfBlocks + r.ProgramPoints[i].WritesOfBlocks
+}
+
+// accessDensity returns the accesses of the blocks of program point i for
+// each of their bytes, 0 if it allocated no bytes.
+func (r Report) accessDensity(i int) float64 {
+	pp := r.ProgramPoints[i]
+	if pp.TotalBytes == 0 {
+		return 0
+	}
+	return float64(r.accesses(i)) / float64(pp.TotalBytes)
+}
+
+// Stack returns the resolved frames of program point i, outermost first.
+func (r Report) Stack(i int) []string {
+	frames := r.ProgramPoints[i].Frames
+	stack := make([]string, 0, len(frames))
+	for j := len(frames) - 1; j >= 0; j-- {
+		stack = append(stack, r.GetFrame(frames[j]))
+	}
+	return stack
+}
+
+// StackID returns an identifier of program point i derived from its resolved
+// frames, which stays the same across reports of the same program.
+func (r Report) StackID(i int) string {
+	sum := sha256.Sum256([]byte(r.StackKey(i)))
+	return hex.EncodeToString(sum[:4])
+}
+
+// StackKey returns the resolved frames of program point i, outermost first,
+// joined in a string which matches the same stack in other reports of the
+// same program. The frames which are only an address, which changes from run
+// to run with ASLR, match any other address.
+func (r Report) StackKey(i int) string {
+	stack := r.Stack(i)
+	for n, frame := range stack {
+		if isAddress(frame) {
+			stack[n] = anyAddress
+		}
+	}
+	return strings.Join(stack, "\n")
+}
+
+// anyAddress replaces the unresolved addresses in the keys of the stacks, and
+// anySymbol their symbol indexes.
+const (
+	anyAddress = "0x?"
+	anySymbol  = -1
+)
+
+// StackKeySymbols returns the symbol indexes of the frames of program point
+// i, outermost first, with the ones which are only an address replaced by
+// anySymbol, so stacks of merged runs match like with StackKey.
+func (r Report) StackKeySymbols(i int) []int {
+	stack := r.StackSymbols(i)
+	for n, sym := range stack {
+		if isAddress(r.Symbol(sym)) {
+			stack[n] = anySymbol
+		}
+	}
+	return stack
+}
+
+// StackSymbols returns the symbol indexes of the frames of program point i,
+// outermost first.
+func (r Report) StackSymbols(i int) []int {
+	frames := r.ProgramPoints[i].Frames
+	stack := make([]int, 0, len(frames))
+	for j := len(frames) - 1; j >= 0; j-- {
+		stack = append(stack, r.frameSym[frames[j]])
+	}
+	return stack
+}
+
+// Symbol returns the symbol with the given index.
+func (r Report) Symbol(n int) string {
+	return r.symbols[n]
+}
+
+// BytesLabel returns the unit of the bytes counts, as given in the report.
+func (r Report) BytesLabel() string {
+	if r.BytesUnit != "" {
+		return r.BytesUnit
+	}
+	return "bytes"
+}
+
+// BlocksLabel returns the unit of the blocks counts, as given in the report.
+func (r Report) BlocksLabel() string {
+	if r.BlocksUnit != "" {
+		return r.BlocksUnit
+	}
+	return "blocks"
+}
+
 func (r Report) GetFrame(i int) string {
-	return strings.Split(r.FramesTable[i], ": ")[1]
+	return r.symbols[r.frameSym[i]]
 }
 
 type ProgramPoint struct {
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGetFrame(t *testing.T) {
+	tests := []struct {
+		frame string
+		want  string
+	}{
+		{
+			frame: "0x109186: main (a.c:5)",
+			want:  "main (a.c:5)",
+		},
+		{
+			frame: "0x1091C0: {lambda(int)#1}::operator()(int) const: helper (a.cpp:7)",
+			want:  "{lambda(int)#1}::operator()(int) const: helper (a.cpp:7)",
+		},
+		{
+			frame: "[root]",
+			want:  "[root]",
+		},
+	}
+
+	for _, tt := range tests {
+		r := Report{FramesTable: []string{tt.frame}}
+		r.resolveFrames()
+		if got := r.GetFrame(0); got != tt.want {
+			t.Errorf("GetFrame(%q) = %q, want %q", tt.frame, got, tt.want)
+		}
+	}
+}
+
+func TestProgramPointHasFrame(t *testing.T) {
+	r := Report{
+		ProgramPoints: []ProgramPoint{{Frames: []int{0}}},
+		FramesTable:   []string{"0x1091C0: {lambda(int)#1}::operator()(int) const: helper (a.cpp:7)"},
+	}
+	r.resolveFrames()
+
+	if !r.ProgramPointHasFrame(0, "helper") {
+		t.Error("expected the frame after the second \": \" to be matched")
+	}
+}
+
+func TestDryRunFilterCmd(t *testing.T) {
+	r := Report{
+		ProgramPoints: []ProgramPoint{{TotalBytes: 100}, {TotalBytes: 200}, {TotalBytes: 300}, {TotalBytes: 400}},
+	}
+	opts := &options{filterCmd: `grep -Eq '"tb":(200|400)'`}
+
+	var out strings.Builder
+	if err := printDryRun(&out, &r, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"dropped", "shown", "dropped", "shown"}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")[1:]
+	if len(lines) != len(want) {
+		t.Fatalf("got %d program points, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i, line := range lines {
+		if got := strings.TrimSuffix(strings.Fields(line)[2], ":"); got != want[i] {
+			t.Errorf("program point #%d: got %q, want %s", i, line, want[i])
+		}
+	}
+}
+
+func TestFoldTemplates(t *testing.T) {
+	tests := []struct {
+		sym  string
+		want string
+	}{
+		{
+			sym:  "std::vector<int>::push_back(int const&)",
+			want: "std::vector<>::push_back(int const&)",
+		},
+		{
+			sym:  "std::map<int, std::vector<int> >::find(int const&)",
+			want: "std::map<>::find(int const&)",
+		},
+		{
+			sym:  "std::ostream::operator<<(int)",
+			want: "std::ostream::operator<<(int)",
+		},
+		{
+			sym:  "bool operator< <int>(int, int)",
+			want: "bool operator< <>(int, int)",
+		},
+		{
+			sym:  "f<int(int, int)",
+			want: "f<int(int, int)",
+		},
+		{
+			sym:  "main",
+			want: "main",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := foldTemplates(tt.sym); got != tt.want {
+			t.Errorf("foldTemplates(%q) = %q, want %q", tt.sym, got, tt.want)
+		}
+	}
+}
+
+func TestStripArgs(t *testing.T) {
+	tests := []struct {
+		sym  string
+		want string
+	}{
+		{
+			sym:  "ns::f<int>(int, char) const",
+			want: "ns::f<int>",
+		},
+		{
+			sym:  "{lambda(int)#1}::operator()(int) const",
+			want: "{lambda(int)#1}::operator()",
+		},
+		{
+			sym:  "std::ostream::operator<<(int)",
+			want: "std::ostream::operator<<",
+		},
+		{
+			sym:  "f(std::function<void (int)>)",
+			want: "f",
+		},
+		{
+			sym:  "f(int",
+			want: "f(int",
+		},
+		{
+			sym:  "(anonymous)",
+			want: "(anonymous)",
+		},
+		{
+			sym:  "main",
+			want: "main",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := stripArgs(tt.sym); got != tt.want {
+			t.Errorf("stripArgs(%q) = %q, want %q", tt.sym, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		cmd     string
+		program string
+		args    []string
+	}{
+		{
+			cmd:     "./prog --flag 'a b'",
+			program: "./prog",
+			args:    []string{"--flag", "a b"},
+		},
+		{
+			cmd:     `./prog "a \"b\" $x" c\ d`,
+			program: "./prog",
+			args:    []string{`a "b" $x`, "c d"},
+		},
+		{
+			cmd:     `./prog '' "it's"  	  e`,
+			program: "./prog",
+			args:    []string{"", "it's", "e"},
+		},
+		{
+			cmd:     "./prog",
+			program: "./prog",
+			args:    []string{},
+		},
+		{
+			cmd: "  ",
+		},
+	}
+
+	for _, tt := range tests {
+		program, args := splitCommand(tt.cmd)
+		if program != tt.program || !slices.Equal(args, tt.args) {
+			t.Errorf("splitCommand(%q) = %q, %q, want %q, %q", tt.cmd, program, args, tt.program, tt.args)
+		}
+	}
+}
+
+func TestUnquoteRaw(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{
+			s:    `"main (a.c:5)"`,
+			want: "main (a.c:5)",
+		},
+		{
+			s:    "\"f\xff\xfe (a.c:5)\"",
+			want: "f\xff\xfe (a.c:5)",
+		},
+		{
+			s:    `"a\"b\\c\/d\n\t"`,
+			want: "a\"b\\c/d\n\t",
+		},
+		{
+			s:    `"\u00e9\ud83d\ude00"`,
+			want: "\u00e9\U0001F600",
+		},
+		{
+			s:    `"\ud83d"`,
+			want: "\uFFFD",
+		},
+		{
+			s:       `"a\q"`,
+			wantErr: true,
+		},
+		{
+			s:       `"a\"`,
+			wantErr: true,
+		},
+		{
+			s:       `main`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := unquoteRaw([]byte(tt.s))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("unquoteRaw(%q) = %q, want an error", tt.s, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("unquoteRaw(%q) = %q, %v, want %q", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseReport(t *testing.T) {
+	const run1 = `{"dhatFileVersion": 2, "mode": "heap", "tu": "instrs", "te": 100, "bklt": true, "bkacc": true,
+		"pps": [{"tb": 10, "tbk": 1, "fs": [1, 2]}],
+		"ftbl": ["[root]", "0x1: malloc (in libc.so)", "0x2: main (a.c:5)"]}`
+	const run2 = `{"dhatFileVersion": 2, "mode": "heap", "tu": "instrs", "te": 200, "bklt": true, "bkacc": false,
+		"pps": [{"tb": 20, "tbk": 2, "fs": [1]}, {"tb": 30, "tbk": 3, "fs": [1, 2]}],
+		"ftbl": ["[root]", "0x3: calloc (in libc.so)", "0x4: g (b.c:7)"]}`
+
+	tests := []struct {
+		name      string
+		content   string
+		partialOK bool
+		stacks    [][]string
+		bytes     []int
+		wantErr   string
+	}{
+		{
+			name:    "one run",
+			content: run1,
+			stacks:  [][]string{{"main (a.c:5)", "malloc (in libc.so)"}},
+			bytes:   []int{10},
+		},
+		{
+			name:    "concatenated runs",
+			content: run1 + "\n" + run2,
+			stacks: [][]string{
+				{"main (a.c:5)", "malloc (in libc.so)"},
+				{"calloc (in libc.so)"},
+				{"g (b.c:7)", "calloc (in libc.so)"},
+			},
+			bytes: []int{10, 20, 30},
+		},
+		{
+			name:    "different modes",
+			content: run1 + strings.Replace(run2, `"heap"`, `"copy"`, 1),
+			wantErr: `object #2: cannot merge mode "copy" with mode "heap"`,
+		},
+		{
+			name:    "truncated",
+			content: run1[:len(run1)-10],
+			wantErr: "is truncated",
+		},
+		{
+			name:      "truncated with -partial-ok",
+			content:   strings.Replace(run2, `"ftbl"`, `"x"`, 1)[:strings.Index(run2, `{"tb": 30`)],
+			partialOK: true,
+			stacks:    [][]string{{"[truncated]"}},
+			bytes:     []int{20},
+		},
+		{
+			name:    "not JSON",
+			content: "\n  <html>",
+			wantErr: "is not a JSON file",
+		},
+	}
+
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "dhat.json")
+		if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		r, err := parseReport(file, tt.partialOK, 1)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(r.ProgramPoints) != len(tt.stacks) {
+			t.Errorf("%s: got %d program points, want %d", tt.name, len(r.ProgramPoints), len(tt.stacks))
+			continue
+		}
+		for i := range r.ProgramPoints {
+			if got := r.Stack(i); !slices.Equal(got, tt.stacks[i]) {
+				t.Errorf("%s: stack of #%d = %q, want %q", tt.name, i, got, tt.stacks[i])
+			}
+			if got := r.ProgramPoints[i].TotalBytes; got != tt.bytes[i] {
+				t.Errorf("%s: bytes of #%d = %d, want %d", tt.name, i, got, tt.bytes[i])
+			}
+		}
+	}
+
+	// The fields of the runs are merged as well.
+	file := filepath.Join(t.TempDir(), "dhat.json")
+	if err := os.WriteFile(file, []byte(run1+run2), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := parseReport(file, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TimeAtEnd != 200 || !r.BlockLifetimesRecorded || r.BlockAccessesRecorded {
+		t.Errorf("merged te=%d bklt=%v bkacc=%v, want 200, true, false",
+			r.TimeAtEnd, r.BlockLifetimesRecorded, r.BlockAccessesRecorded)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		keys []string
+	}{
+		{"jjk", []string{"j", "j", "k"}},
+		{"\033[A\033OB\033[5~\033[6~", []string{"up", "down", "pgup", "pgdn"}},
+		{"\033[H\033[4~", []string{"home", "end"}},
+		{"\033", []string{"esc"}},
+		{"\033q", []string{"esc", "q"}},
+		{"\033[1;5Cx", []string{"x"}},
+		{"a\rb\x7f\b\x03", []string{"a", "enter", "b", "backspace", "backspace", "ctrl-c"}},
+		{"é\x01", []string{"é"}},
+	}
+
+	for _, tt := range tests {
+		if keys := parseKeys([]byte(tt.in)); !slices.Equal(keys, tt.keys) {
+			t.Errorf("parseKeys(%q) = %q, want %q", tt.in, keys, tt.keys)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// printMemStats writes how big the parts of the decoded report r are, to
+// tell which of them take the memory of dhatless on big DHAT files.
+func printMemStats(w io.Writer, r *Report) {
+	tableBytes := 0
+	for _, frame := range r.FramesTable {
+		tableBytes += len(frame)
+	}
+	symbolBytes := 0
+	for _, sym := range r.symbols {
+		symbolBytes += len(sym)
+	}
+	frames, accesses := 0, 0
+	for _, pp := range r.ProgramPoints {
+		frames += len(pp.Frames)
+		accesses += len(pp.BlockAccesses)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Frame table entries:\t%d\t(%d bytes of strings)\n", len(r.FramesTable), tableBytes)
+	fmt.Fprintf(tw, "Distinct symbols:\t%d\t(%d bytes of strings)\n", len(r.symbols), symbolBytes)
+	fmt.Fprintf(tw, "Program points:\t%d\n", len(r.ProgramPoints))
+	fmt.Fprintf(tw, "Frames in all stacks:\t%d\n", frames)
+	fmt.Fprintf(tw, "Block access entries:\t%d\n", accesses)
+	tw.Flush()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// unclassifiedModule is the module of the program points without a source
+// file matched by -modules.
+const unclassifiedModule = "unclassified"
+
+// moduleRule maps the source files matching a glob to a module.
+type moduleRule struct {
+	pattern string
+	module  string
+}
+
+// parseModulesFile returns the rules in file, one per line: a glob of source
+// files followed by the name of their module, e.g. "src/net/* networking".
+// Empty lines and comments are skipped.
+func parseModulesFile(file string) ([]moduleRule, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []moduleRule
+	for n, line := range strings.Split(string(content), "\n") {
+		line := strings.Trim(line, " \t\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		pattern, module, _ := strings.Cut(line, " ")
+		module = strings.TrimSpace(module)
+		if module == "" {
+			return nil, fmt.Errorf("%s:%d: expected a glob and a module name", file, n+1)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, n+1, err)
+		}
+		rules = append(rules, moduleRule{pattern: pattern, module: module})
+	}
+	return rules, nil
+}
+
+// matchModule returns the module of the first of the rules whose glob matches
+// the source file, or one of its trailing parts, so "src/net/*" matches
+// "/home/ci/src/net/a.cpp".
+func matchModule(rules []moduleRule, file string) (string, bool) {
+	for _, rule := range rules {
+		for tail := file; ; {
+			if ok, _ := path.Match(rule.pattern, tail); ok {
+				return rule.module, true
+			}
+			i := strings.IndexByte(tail, '/')
+			if i < 0 {
+				break
+			}
+			tail = tail[i+1:]
+		}
+	}
+	return "", false
+}
+
+// moduleOf returns the module of the program point i: the one of the source
+// file of its innermost displayed frame which has one, so allocator frames
+// without sources don't hide the caller.
+func (o *options) moduleOf(r *Report, i int) string {
+	for _, frame := range o.visibleFrames(r, r.ProgramPoints[i]) {
+		file, _, ok := frameSource(r.GetFrame(frame))
+		if !ok {
+			continue
+		}
+		if module, ok := matchModule(o.modRules, file); ok {
+			return module
+		}
+		break
+	}
+	return unclassifiedModule
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// withPager calls f with a writer to the pager given by the PAGER environment
+// variable, less by default, if STDOUT is a terminal. Otherwise f writes
+// directly to STDOUT.
+func withPager(f func(w io.Writer) error) error {
+	if !isTerminal(os.Stdout) {
+		return f(os.Stdout)
+	}
+
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		// -R shows the colors of -heat instead of their escape codes.
+		pager = "less -R"
+	}
+
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(stdin)
+	err = f(w)
+	_ = w.Flush()
+	stdin.Close()
+
+	if waitErr := cmd.Wait(); err == nil {
+		err = waitErr
+	}
+	return err
+}
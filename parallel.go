@@ -0,0 +1,42 @@
+package main
+
+import "sync"
+
+// chunk is the range [lo, hi) of the elements processed by one worker.
+type chunk struct {
+	lo, hi int
+}
+
+// chunks splits n elements in at most workers contiguous chunks of about the
+// same size, in order.
+func chunks(n, workers int) []chunk {
+	workers = max(min(workers, n), 1)
+	cs := make([]chunk, workers)
+	for k := range cs {
+		cs[k] = chunk{lo: n * k / workers, hi: n * (k + 1) / workers}
+	}
+	return cs
+}
+
+// forEachChunk calls f for each of the chunks of n elements, on -concurrency
+// workers at the same time, and waits for all of them. With a single worker
+// f is called once, for all the elements. The results of f must be merged
+// in the order of the chunks for the output to be the same as with one
+// worker.
+func forEachChunk(n, workers int, f func(k int, c chunk)) {
+	cs := chunks(n, workers)
+	if len(cs) == 1 {
+		f(0, cs[0])
+		return
+	}
+
+	var wg sync.WaitGroup
+	for k, c := range cs {
+		wg.Add(1)
+		go func(k int, c chunk) {
+			defer wg.Done()
+			f(k, c)
+		}(k, c)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// truncatedFrame replaces the entries of the frame table of a truncated DHAT
+// file which are missing.
+const truncatedFrame = "[truncated]"
+
+// decodePartial decodes the next DHAT report of dec into r, one element at a
+// time, so that everything before the end of the input is kept if it is
+// truncated, which is reported by returning true.
+func decodePartial(dec *json.Decoder, r *Report) (bool, error) {
+	// The fields other than the program points and the frame table are
+	// collected and decoded together at the end.
+	fields := make(map[string]json.RawMessage)
+	truncated, err := decodePartialObject(dec, r, fields)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return false, err
+	}
+
+	if truncated {
+		// The frame table is usually the last part of the file.
+		for _, pp := range r.ProgramPoints {
+			for _, frame := range pp.Frames {
+				for len(r.FramesTable) <= frame {
+					r.FramesTable = append(r.FramesTable, truncatedFrame)
+				}
+			}
+		}
+	}
+	return truncated, nil
+}
+
+func decodePartialObject(dec *json.Decoder, r *Report, fields map[string]json.RawMessage) (bool, error) {
+	if tok, err := dec.Token(); err != nil {
+		return isTruncation(err), ignoreTruncation(err)
+	} else if tok != json.Delim('{') {
+		return false, fmt.Errorf("expected DHAT report object, got %v", tok)
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return isTruncation(err), ignoreTruncation(err)
+		}
+		key, _ := tok.(string)
+		switch key {
+		case "pps":
+			err = decodeArray(dec, func() error {
+				var pp ProgramPoint
+				if err := dec.Decode(&pp); err != nil {
+					return err
+				}
+				r.ProgramPoints = append(r.ProgramPoints, pp)
+				return nil
+			})
+		case "ftbl":
+			err = decodeArray(dec, func() error {
+				var raw json.RawMessage
+				if err := dec.Decode(&raw); err != nil {
+					return err
+				}
+				frame, err := decodeFrame(raw)
+				if err != nil {
+					return err
+				}
+				r.FramesTable = append(r.FramesTable, frame)
+				return nil
+			})
+		default:
+			var raw json.RawMessage
+			if err = dec.Decode(&raw); err == nil {
+				fields[key] = raw
+			}
+		}
+		if err != nil {
+			return isTruncation(err), ignoreTruncation(err)
+		}
+	}
+
+	_, err := dec.Token()
+	return isTruncation(err), ignoreTruncation(err)
+}
+
+// decodeArray calls decodeElem for each element of the JSON array which is
+// next in dec.
+func decodeArray(dec *json.Decoder, decodeElem func() error) error {
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("expected array, got %v", tok)
+	}
+	for dec.More() {
+		if err := decodeElem(); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// unexpectedEnd is the message of the syntax error given by the decoder when
+// the input ends right after a comma between two values.
+const unexpectedEnd = "unexpected end of JSON input"
+
+func isTruncation(err error) bool {
+	var serr *json.SyntaxError
+	if errors.As(err, &serr) && serr.Error() == unexpectedEnd {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func ignoreTruncation(err error) error {
+	if isTruncation(err) {
+		return nil
+	}
+	return err
+}
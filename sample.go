@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// samplePPs returns n of the program points pps chosen at random, in their
+// original order, and a note with the seed used, which gives the same sample
+// when passed to -seed. If seed is 0 a new one is chosen.
+func samplePPs(pps []int, n int, seed int64) ([]int, string) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	note := fmt.Sprintf("Random sample of %d of %d allocations, seed %d", min(n, len(pps)), len(pps), seed)
+	if n >= len(pps) {
+		return pps, note
+	}
+
+	// Partial Fisher-Yates shuffle of the positions in pps. The numbers
+	// don't need to be unpredictable, only the same for the same seed on
+	// every platform and Go version, so splitmix64 is enough.
+	state := uint64(seed)
+	next := func(bound int) int {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		z ^= z >> 31
+		return int(z % uint64(bound))
+	}
+	pos := make([]int, len(pps))
+	for p := range pos {
+		pos[p] = p
+	}
+	for k := 0; k < n; k++ {
+		j := k + next(len(pos)-k)
+		pos[k], pos[j] = pos[j], pos[k]
+	}
+	pos = pos[:n]
+	slices.Sort(pos)
+
+	sample := make([]int, n)
+	for k, p := range pos {
+		sample[k] = pps[p]
+	}
+	return sample, note
+}
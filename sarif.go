@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// The subset of SARIF 2.1.0 needed to report allocation sites. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+const sarifRuleID = "dhat-allocation"
+
+// printSarif writes the program points pps as the results of a SARIF log,
+// located at the innermost of their frames which has a source location.
+func printSarif(w io.Writer, r *Report, pps []int, opts *options) error {
+	results := make([]sarifResult, 0, len(pps))
+
+	for _, i := range pps {
+		pp := r.ProgramPoints[i]
+		result := sarifResult{
+			RuleID: sarifRuleID,
+			Level:  "warning",
+			Message: sarifMessage{
+				Text: fmt.Sprintf("%d %s in %d %s", pp.TotalBytes, r.BytesLabel(), pp.TotalBlocks, r.BlocksLabel()),
+			},
+		}
+		for _, frame := range opts.visibleFrames(r, pp) {
+			sym := r.GetFrame(frame)
+			file, line, ok := frameSource(sym)
+			if !ok {
+				continue
+			}
+			name, _, _ := splitFrameLocation(sym)
+			result.Message.Text += " allocated by " + name
+			result.Locations = []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: opts.trimPath(file)},
+					Region:           sarifRegion{StartLine: line},
+				},
+			}}
+			break
+		}
+		results = append(results, result)
+	}
+
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:           "dhatless",
+					InformationURI: "https://github.com/aburdulescu/dhatless",
+					Rules: []sarifRule{{
+						ID:               sarifRuleID,
+						ShortDescription: sarifMessage{Text: "Allocation site recorded by DHAT"},
+					}},
+				},
+			},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(log)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// fieldDocs describes the fields of the DHAT file format understood by
+// dhatless, as documented on the Report and ProgramPoint structs.
+var fieldDocs = map[string]string{
+	"Version":                 "Version number of the format, incremented on each backwards-incompatible change",
+	"InvocationMode":          "The invocation mode, free-form",
+	"StackFrameVerb":          `The verb used before the stack frames, i.e. "<verb> at {"`,
+	"BlockLifetimesRecorded":  "Are block lifetimes recorded? Affects whether some other fields are present",
+	"BlockAccessesRecorded":   "Are block accesses recorded? Affects whether some other fields are present",
+	"ByteUnit":                `Byte unit, "byte" if omitted`,
+	"BytesUnit":               `Bytes unit, "bytes" if omitted`,
+	"BlocksUnit":              `Blocks unit, "blocks" if omitted`,
+	"TimeUnit":                "Time unit",
+	"MilTimeUnit":             "Time unit for 1,000,000 time units",
+	"ShortLivedTimeThreshold": `The "short-lived" time threshold, in time units (bklt=true only)`,
+	"Cmd":                     "The executed command",
+	"PID":                     "The process ID",
+	"TimeAtEnd":               "The time at the end of execution (t-end)",
+	"TimeAtGlobalMax":         "The time of the global max (t-gmax) (bklt=true only)",
+	"ProgramPoints":           "The program points",
+	"FramesTable":             "Frame table",
+	"TotalBytes":              "Total bytes",
+	"TotalBlocks":             "Total blocks",
+	"TotalLifetimesOfBlocks":  "Total lifetimes of all blocks allocated at this PP (bklt=true only)",
+	"MaxBytes":                "The maximum bytes for this PP (bklt=true only)",
+	"MaxBlocks":               "The maximum blocks for this PP (bklt=true only)",
+	"BytesAtTgmax":            "The bytes at t-gmax for this PP (bklt=true only)",
+	"BlocksAtTgmax":           "The blocks at t-gmax for this PP (bklt=true only)",
+	"BytesAtTend":             "The bytes at t-end for this PP (bklt=true only)",
+	"BlocksAtTend":            "The blocks at t-end for this PP (bklt=true only)",
+	"ReadsOfBlocks":           "The reads of blocks for this PP (bkacc=true only)",
+	"WritesOfBlocks":          "The writes of blocks for this PP (bkacc=true only)",
+	"BlockAccesses":           "The exact accesses of blocks for this PP, run-length encoded (bkacc=true only, optional)",
+	"Frames":                  `Indexes into the "ftbl" array, innermost frame first`,
+}
+
+// printSchema writes the fields of the DHAT file format which dhatless reads,
+// found by reflection on the Report and ProgramPoint structs.
+func printSchema(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "OBJECT\tFIELD\tKEY\tTYPE\tDESCRIPTION")
+	for _, t := range []reflect.Type{reflect.TypeOf(Report{}), reflect.TypeOf(ProgramPoint{})} {
+		object := "report"
+		if t == reflect.TypeOf(ProgramPoint{}) {
+			object = "pps[]"
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || key == "" {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", object, f.Name, key, jsonType(f.Type), fieldDocs[f.Name])
+		}
+	}
+	tw.Flush()
+}
+
+// jsonType returns the JSON type of the values of Go type t.
+func jsonType(t reflect.Type) string {
+	if t.Kind() == reflect.Slice {
+		if t.Elem().Kind() == reflect.Struct {
+			return "array of objects"
+		}
+		return "array of " + jsonType(t.Elem()) + "s"
+	}
+	if name, ok := jsonTypes[t.Kind()]; ok {
+		return name
+	}
+	return t.Kind().String()
+}
+
+var jsonTypes = map[reflect.Kind]string{
+	reflect.Bool:   "boolean",
+	reflect.Int:    "integer",
+	reflect.String: "string",
+}
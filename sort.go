@@ -0,0 +1,185 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// sortKey describes one of the values accepted by -sort.
+type sortKey struct {
+	// Compares program points a and b, in ascending order.
+	cmp func(r *Report, a, b int) int
+
+	// Sort in ascending order unless another direction is given with
+	// -sort-dir. Keys are sorted in descending order otherwise.
+	ascending bool
+
+	// The key uses fields only present when block accesses are recorded.
+	needsAccesses bool
+
+	// The key uses fields only present when block lifetimes are recorded.
+	needsLifetimes bool
+
+	// If set, called with the program points before they are sorted, for
+	// keys which depend on all of them.
+	prepare func(r *Report, pps []int)
+
+	// If set, orders the program points which cmp finds equal. Unlike cmp,
+	// it isn't reversed by the sort direction.
+	tie func(r *Report, a, b int) int
+}
+
+// moreBytes orders the program points a and b by their bytes, the biggest
+// first.
+func moreBytes(r *Report, a, b int) int {
+	return cmp.Compare(r.ProgramPoints[b].TotalBytes, r.ProgramPoints[a].TotalBytes)
+}
+
+var sortKeys = map[string]sortKey{
+	"bytes": {
+		cmp: func(r *Report, a, b int) int {
+			return cmp.Compare(r.ProgramPoints[a].TotalBytes, r.ProgramPoints[b].TotalBytes)
+		},
+	},
+	"blocks": {
+		cmp: func(r *Report, a, b int) int {
+			return cmp.Compare(r.ProgramPoints[a].TotalBlocks, r.ProgramPoints[b].TotalBlocks)
+		},
+	},
+	"end": {
+		cmp: func(r *Report, a, b int) int {
+			return cmp.Compare(r.ProgramPoints[a].BytesAtTend, r.ProgramPoints[b].BytesAtTend)
+		},
+		needsLifetimes: true,
+	},
+	"reads": {
+		cmp: func(r *Report, a, b int) int {
+			return cmp.Compare(r.ProgramPoints[a].ReadsOfBlocks, r.ProgramPoints[b].ReadsOfBlocks)
+		},
+		needsAccesses: true,
+	},
+	"writes": {
+		cmp: func(r *Report, a, b int) int {
+			return cmp.Compare(r.ProgramPoints[a].WritesOfBlocks, r.ProgramPoints[b].WritesOfBlocks)
+		},
+		needsAccesses: true,
+	},
+	"access-density": {
+		cmp: func(r *Report, a, b int) int {
+			return cmp.Compare(r.accessDensity(a), r.accessDensity(b))
+		},
+		tie: func(r *Report, a, b int) int {
+			return cmp.Compare(r.accesses(b), r.accesses(a))
+		},
+		needsAccesses: true,
+	},
+	"stack": {
+		cmp: func(r *Report, a, b int) int {
+			return slices.CompareFunc(r.StackSymbols(a), r.StackSymbols(b), func(x, y int) int {
+				if x == y {
+					return 0
+				}
+				return strings.Compare(r.Symbol(x), r.Symbol(y))
+			})
+		},
+		ascending: true,
+	},
+	"self": {
+		cmp: func(r *Report, a, b int) int {
+			return cmp.Compare(r.selfBytes(a), r.selfBytes(b))
+		},
+		tie:     moreBytes,
+		prepare: (*Report).sumLeafBytes,
+	},
+	"name": {
+		cmp: func(r *Report, a, b int) int {
+			return strings.Compare(r.leafFrame(a), r.leafFrame(b))
+		},
+		tie:       moreBytes,
+		ascending: true,
+	},
+}
+
+// sortKeyNames returns the valid -sort values, for use in messages.
+func sortKeyNames() string {
+	names := make([]string, 0, len(sortKeys))
+	for name := range sortKeys {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// sortProgramPoints sorts the given program point indexes using key, in
+// ascending or descending order, and breaking the ties with key.tie in its
+// own order. Program points which still compare equal keep their order from
+// the report.
+func sortProgramPoints(r *Report, pps []int, key sortKey, ascending bool) {
+	if key.prepare != nil {
+		key.prepare(r, pps)
+	}
+	slices.SortStableFunc(pps, func(a, b int) int {
+		c := key.cmp(r, a, b)
+		if !ascending {
+			c = -c
+		}
+		if c == 0 && key.tie != nil {
+			c = key.tie(r, a, b)
+		}
+		return c
+	})
+}
+
+// topPercent sorts the program points pps by bytes and returns the biggest
+// ones which together account for at least percent of their bytes, with a
+// note saying how many they were.
+func topPercent(r *Report, pps []int, percent float64) ([]int, string) {
+	sortProgramPoints(r, pps, sortKeys["bytes"], false)
+
+	total := 0
+	for _, i := range pps {
+		total += r.ProgramPoints[i].TotalBytes
+	}
+
+	n, bytes := 0, 0
+	for n < len(pps) && float64(bytes) < float64(total)*percent/100 {
+		bytes += r.ProgramPoints[pps[n]].TotalBytes
+		n++
+	}
+
+	share := 100.0
+	if total > 0 {
+		share = float64(bytes) * 100 / float64(total)
+	}
+	note := fmt.Sprintf(
+		"%d of %d allocations account for %.1f%% of the %d %s",
+		n, len(pps), share, total, r.BytesLabel(),
+	)
+	return pps[:n], note
+}
+
+// showUntil returns the first of the program points pps, in their order, until
+// their bytes add up to more than budget, and a note about the ones left out,
+// if any.
+func showUntil(r *Report, pps []int, budget int, opts *options) ([]int, string) {
+	n, bytes := 0, 0
+	for n < len(pps) && bytes <= budget {
+		bytes += r.ProgramPoints[pps[n]].TotalBytes
+		n++
+	}
+	if n == len(pps) {
+		return pps, ""
+	}
+
+	rest := 0
+	for _, i := range pps[n:] {
+		rest += r.ProgramPoints[i].TotalBytes
+	}
+	note := fmt.Sprintf(
+		"remaining %d sites, %s %s not shown (-show-until %d)",
+		len(pps)-n, opts.bytes(rest), r.BytesLabel(), budget,
+	)
+	return pps[:n], note
+}
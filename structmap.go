@@ -0,0 +1,142 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// structField is a field of the layout of the blocks of a given size, given
+// with -struct-map.
+type structField struct {
+	// The first and last byte offsets of the field.
+	start, end int
+
+	// The name of the field, "" for the offsets the struct map doesn't name.
+	name string
+}
+
+// label returns the name of the field or, if it isn't named, its offsets.
+func (f structField) label() string {
+	if f.name != "" {
+		return f.name
+	}
+	if f.start == f.end {
+		return "+" + strconv.Itoa(f.start)
+	}
+	return fmt.Sprintf("+%d-%d", f.start, f.end)
+}
+
+// parseStructMap returns the layouts in file, by the size of the blocks they
+// describe. Each layout starts with the size in brackets, e.g. "[24]", and is
+// followed by its fields, one per line, as the first and last byte offsets
+// and the name, e.g. "8-15 prev", or just the offset for single bytes.
+// Empty lines and comments are skipped.
+func parseStructMap(file string) (map[int][]structField, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	layouts := make(map[int][]structField)
+	size := -1
+	for n, line := range strings.Split(string(content), "\n") {
+		line := strings.Trim(line, " \t\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			size, err = strconv.Atoi(line[1 : len(line)-1])
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("%s:%d: invalid size %s", file, n+1, line)
+			}
+			continue
+		}
+		if size < 0 {
+			return nil, fmt.Errorf("%s:%d: field before the first [size]", file, n+1)
+		}
+
+		offsets, name, _ := strings.Cut(line, " ")
+		name = strings.TrimSpace(name)
+		first, last, isRange := strings.Cut(offsets, "-")
+		if !isRange {
+			last = first
+		}
+		start, err1 := strconv.Atoi(first)
+		end, err2 := strconv.Atoi(last)
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("%s:%d: expected offsets and a field name", file, n+1)
+		case err1 != nil || err2 != nil || start < 0 || end < start:
+			return nil, fmt.Errorf("%s:%d: invalid offsets %s", file, n+1, offsets)
+		case end >= size:
+			return nil, fmt.Errorf("%s:%d: offsets %s outside of the %d bytes", file, n+1, offsets, size)
+		}
+		layouts[size] = append(layouts[size], structField{start: start, end: end, name: name})
+	}
+
+	for size, fields := range layouts {
+		slices.SortFunc(fields, func(a, b structField) int { return cmp.Compare(a.start, b.start) })
+		for k := 1; k < len(fields); k++ {
+			if fields[k].start <= fields[k-1].end {
+				return nil, fmt.Errorf("%s: fields %s and %s of [%d] overlap", file, fields[k-1].name, fields[k].name, size)
+			}
+		}
+	}
+	return layouts, nil
+}
+
+// structLayout returns the fields covering the size bytes of the blocks of a
+// program point, the ones of the struct map and unnamed ones in between, or
+// nil if the struct map has no layout for this size.
+func (o *options) structLayout(size int) []structField {
+	fields, ok := o.structMap[size]
+	if !ok {
+		return nil
+	}
+
+	layout := make([]structField, 0, 2*len(fields)+1)
+	off := 0
+	for _, f := range fields {
+		if f.start > off {
+			layout = append(layout, structField{start: off, end: f.start - 1})
+		}
+		layout = append(layout, f)
+		off = f.end + 1
+	}
+	if off < size {
+		layout = append(layout, structField{start: off, end: size - 1})
+	}
+	return layout
+}
+
+// hotFields returns the fields of the layout with their access counts, the
+// most accessed first, like "prev 120, next 16, +16-23 4".
+func hotFields(layout []structField, counts []int) string {
+	type fieldCount struct {
+		label string
+		count int
+	}
+	fcs := make([]fieldCount, 0, len(layout))
+	for _, f := range layout {
+		fc := fieldCount{label: f.label()}
+		for off := f.start; off <= f.end && off < len(counts); off++ {
+			fc.count += counts[off]
+		}
+		fcs = append(fcs, fc)
+	}
+	slices.SortStableFunc(fcs, func(a, b fieldCount) int { return cmp.Compare(b.count, a.count) })
+
+	parts := make([]string, len(fcs))
+	for n, fc := range fcs {
+		parts[n] = fc.label + " " + strconv.Itoa(fc.count)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+)
+
+// summaryTop is the number of allocations in the summary of -summary-out.
+const summaryTop = 5
+
+// summarySite is one of the biggest allocations in the summary.
+type summarySite struct {
+	Bytes  int      `json:"bytes"`
+	Blocks int      `json:"blocks"`
+	Frames []string `json:"frames"`
+}
+
+// summary is what -summary-out writes, whatever the output is.
+type summary struct {
+	File        string        `json:"file"`
+	Mode        string        `json:"mode"`
+	TotalBytes  int           `json:"totalBytes"`
+	TotalBlocks int           `json:"totalBlocks"`
+	Sites       int           `json:"sites"`
+	Shown       int           `json:"shown"`
+	Top         []summarySite `json:"top"`
+}
+
+// writeSummary writes to the -summary-out file the totals of the report read
+// from file and the biggest allocations of pps, the program points shown.
+func writeSummary(file string, r *Report, pps []int, opts *options) error {
+	pps = slices.Clone(pps)
+	sortProgramPoints(r, pps, sortKeys["bytes"], false)
+
+	s := summary{
+		File:  file,
+		Mode:  r.InvocationMode,
+		Sites: len(r.ProgramPoints),
+		Shown: len(pps),
+		Top:   []summarySite{},
+	}
+	for _, pp := range r.ProgramPoints {
+		s.TotalBytes += pp.TotalBytes
+		s.TotalBlocks += pp.TotalBlocks
+	}
+	for _, i := range pps[:min(len(pps), summaryTop)] {
+		pp := r.ProgramPoints[i]
+		s.Top = append(s.Top, summarySite{
+			Bytes:  pp.TotalBytes,
+			Blocks: pp.TotalBlocks,
+			Frames: opts.displayStack(opts.visibleStack(r, pp)),
+		})
+	}
+
+	f, err := os.Create(opts.summaryOut)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// symbolMap renames the frames, for -symbol-map.
+type symbolMap struct {
+	// The symbols of the frames by their address.
+	addrs map[uint64]string
+
+	// Substitutes the other names in the symbols of the frames.
+	names *strings.Replacer
+}
+
+// parseSymbolMap returns the symbol map in file, with one "from<TAB>to" line
+// per name. Names starting with 0x are addresses. Empty lines and comments are
+// skipped.
+func parseSymbolMap(file string) (*symbolMap, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &symbolMap{addrs: make(map[uint64]string)}
+	var names []string
+	for n, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || line[0] == '#' {
+			continue
+		}
+		from, to, found := strings.Cut(line, "\t")
+		if !found || from == "" {
+			return nil, fmt.Errorf("%s:%d: expected a name and its replacement separated by a tab", file, n+1)
+		}
+		if addr, ok := parseAddress(from); ok {
+			m.addrs[addr] = to
+		} else {
+			names = append(names, from, to)
+		}
+	}
+	m.names = strings.NewReplacer(names...)
+	return m, nil
+}
+
+// parseAddress returns the value of the address s, like "0x401136".
+func parseAddress(s string) (uint64, bool) {
+	if !isAddress(s) {
+		return 0, false
+	}
+	addr, err := strconv.ParseUint(s[2:], 16, 64)
+	return addr, err == nil
+}
+
+// mapAddresses sets the symbol of the frames of r whose address is in the
+// symbol map, whatever DHAT resolved them to.
+func (m *symbolMap) mapAddresses(r *Report) {
+	changed := false
+	for i, frame := range r.FramesTable {
+		addr, _, _ := strings.Cut(frame, ": ")
+		value, ok := parseAddress(addr)
+		if !ok {
+			continue
+		}
+		if sym, ok := m.addrs[value]; ok {
+			r.FramesTable[i] = addr + ": " + sym
+			changed = true
+		}
+	}
+	if changed {
+		r.resolveFrames()
+	}
+}
+
+// mapNames substitutes the names of the symbol map in the symbols of the
+// frames of r.
+func (m *symbolMap) mapNames(r *Report) {
+	r.MapFrames(m.names.Replace)
+}
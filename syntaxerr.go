@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// syntaxContextLen is the number of bytes shown on each side of the offset of
+// a JSON syntax error.
+const syntaxContextLen = 40
+
+// syntaxError adds to the JSON syntax error err, if it is one, its offset in
+// file and the bytes around it. The offsets of the decoder start after the
+// skipped bytes of whitespace at the beginning of the file.
+func syntaxError(file string, skipped int64, err error) error {
+	var serr *json.SyntaxError
+	if !errors.As(err, &serr) {
+		return err
+	}
+	offset := skipped + serr.Offset
+	near := syntaxContext(file, offset)
+	if near == "" {
+		return fmt.Errorf("%s: invalid JSON at byte %d: %w", file, offset, err)
+	}
+	return fmt.Errorf("%s: invalid JSON at byte %d, near %q: %w", file, offset, near, err)
+}
+
+// syntaxContext returns the bytes of file around offset, or "" if they
+// cannot be read again, for example when file is a URL.
+func syntaxContext(file string, offset int64) string {
+	if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+		return ""
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	start := max(offset-syntaxContextLen, 0)
+	buf := make([]byte, offset-start+syntaxContextLen)
+	n, err := f.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return ""
+	}
+	return string(buf[:n])
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// terminal is the controlling terminal of dhatless, put in raw mode for -tui,
+// so that keys are read as they are typed and not echoed.
+type terminal struct {
+	tty *os.File
+
+	// The settings of the terminal before raw mode, as printed by stty -g.
+	saved string
+}
+
+// openTerminal opens the controlling terminal and puts it in raw mode. The
+// stty command is used for that, as the standard library has no portable way
+// to do it.
+func openTerminal() (*terminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("-tui needs a terminal: %w", err)
+	}
+	t := &terminal{tty: tty}
+
+	saved, err := t.stty("-g")
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+	t.saved = strings.TrimSpace(saved)
+	if _, err := t.stty("raw", "-echo"); err != nil {
+		tty.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// stty runs stty with args on the terminal and returns what it printed.
+func (t *terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.tty
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// size returns the number of rows and columns of the terminal, from stty,
+// else from the LINES and COLUMNS environment variables, else 24 by 80.
+func (t *terminal) size() (int, int) {
+	if out, err := t.stty("size"); err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return rows, cols
+		}
+	}
+	rows, err := strconv.Atoi(os.Getenv("LINES"))
+	if err != nil || rows <= 0 {
+		rows = 24
+	}
+	cols, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || cols <= 0 {
+		cols = 80
+	}
+	return rows, cols
+}
+
+// Read reads the keys typed, as the bytes of one or more of them.
+func (t *terminal) Read(p []byte) (int, error) {
+	return t.tty.Read(p)
+}
+
+// Write writes p to the terminal.
+func (t *terminal) Write(p []byte) (int, error) {
+	return t.tty.Write(p)
+}
+
+// restore puts the terminal back in the mode it was before openTerminal and
+// closes it.
+func (t *terminal) restore() error {
+	_, err := t.stty(t.saved)
+	if cerr := t.tty.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
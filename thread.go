@@ -0,0 +1,29 @@
+package main
+
+// unknownThread is the thread of the program points whose frames don't match
+// -thread-re.
+const unknownThread = "unknown"
+
+// threadOf returns the thread tag found by -thread-re in the frames of the
+// program point i, outermost first: the last submatch of the regexp, or all of
+// the match if it has no groups.
+func (o *options) threadOf(r *Report, i int) string {
+	for _, frame := range r.Stack(i) {
+		m := o.threadRe.FindStringSubmatch(frame)
+		if m == nil {
+			continue
+		}
+		if tag := m[len(m)-1]; tag != "" {
+			return tag
+		}
+		return m[0]
+	}
+	return unknownThread
+}
+
+// groupByThread aggregates the program points pps of r by their thread, as
+// returned by threadOf. The groups are returned sorted by bytes, biggest
+// first.
+func groupByThread(r *Report, pps []int, opts *options) []group {
+	return groupByLabel(r, pps, "thread", func(i int) string { return opts.threadOf(r, i) })
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+)
+
+// trendSite holds the bytes allocated at one allocation site in each of the
+// reports of a trend.
+type trendSite struct {
+	stack []string
+
+	// Bytes allocated in each report, -1 if the site isn't in it.
+	bytes []int
+}
+
+// growing reports whether the site is in all reports and its bytes never
+// decrease from one to the next, growing overall.
+func (s trendSite) growing() bool {
+	for n := 1; n < len(s.bytes); n++ {
+		if s.bytes[n-1] < 0 || s.bytes[n] < s.bytes[n-1] {
+			return false
+		}
+	}
+	return s.bytes[len(s.bytes)-1] > s.bytes[0]
+}
+
+// trendSites matches the program points of the reports by their resolved
+// stack and returns the bytes of each site in every report, growing sites
+// first, then by their bytes in the last report.
+func trendSites(reports []*Report, pps [][]int) []trendSite {
+	index := make(map[string]int)
+	var sites []trendSite
+	for n, r := range reports {
+		for _, i := range pps[n] {
+			key := r.StackKey(i)
+			k, ok := index[key]
+			if !ok {
+				k = len(sites)
+				index[key] = k
+				bytes := make([]int, len(reports))
+				for j := range bytes {
+					bytes[j] = -1
+				}
+				sites = append(sites, trendSite{stack: r.Stack(i), bytes: bytes})
+			}
+			sites[k].bytes[n] = max(sites[k].bytes[n], 0) + r.ProgramPoints[i].TotalBytes
+		}
+	}
+
+	last := len(reports) - 1
+	slices.SortStableFunc(sites, func(a, b trendSite) int {
+		if a.growing() != b.growing() {
+			if a.growing() {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(b.bytes[last], a.bytes[last])
+	})
+	return sites
+}
+
+// runTrend compares the program points pps of report, read from file, with
+// the ones of the older reports given with -trend, printing the bytes of every
+// site in each.
+func runTrend(w io.Writer, file string, report *Report, pps []int, opts *options) error {
+	reports := make([]*Report, 0, len(opts.trendFiles)+1)
+	reportPPs := make([][]int, 0, len(opts.trendFiles)+1)
+	for _, file := range opts.trendFiles {
+		r, err := opts.loadReport(file)
+		if err != nil {
+			return err
+		}
+		selected, err := opts.selectProgramPoints(r)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, r)
+		reportPPs = append(reportPPs, selected)
+	}
+	reports = append(reports, report)
+	reportPPs = append(reportPPs, pps)
+
+	sites := trendSites(reports, reportPPs)
+	if opts.top > 0 && len(sites) > opts.top {
+		sites = sites[:opts.top]
+	}
+
+	printTrend(w, report, append(slices.Clone(opts.trendFiles), file), sites, opts)
+	return nil
+}
+
+// printTrend writes a table with the bytes of the sites in each of the
+// files, followed by their stacks.
+func printTrend(w io.Writer, report *Report, files []string, sites []trendSite, opts *options) {
+	printHeader(w, report, opts)
+
+	fmt.Fprintln(w, "Reports:")
+	for n, file := range files {
+		fmt.Fprintf(w, "  #%d %s\n", n+1, file)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "SITE")
+	for n := range files {
+		fmt.Fprintf(tw, "\t#%d", n+1)
+	}
+	fmt.Fprintln(tw, "\tTREND")
+	for n, s := range sites {
+		fmt.Fprintf(tw, "%d", n+1)
+		for _, bytes := range s.bytes {
+			if bytes < 0 {
+				fmt.Fprint(tw, "\t")
+			} else {
+				fmt.Fprintf(tw, "\t%s", opts.bytes(bytes))
+			}
+		}
+		trend := ""
+		if s.growing() {
+			trend = "growing"
+		}
+		fmt.Fprintf(tw, "\t%s\n", trend)
+	}
+	tw.Flush()
+
+	for n, s := range sites {
+		fmt.Fprintf(w, "\n==== Site #%d ====\n", n+1)
+		for _, frame := range opts.limitRepeats(opts.displayStack(s.stack)) {
+			fmt.Fprintf(w, "%s\n", frame)
+		}
+	}
+}
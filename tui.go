@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const tuiHelp = `Keys:
+  Up, Down, k, j      select the previous or next allocation
+  PgUp, PgDn          move a page up or down
+  Home, End, g, G     select the first or last allocation
+  s                   sort by the next key, the file order after the last one
+  r                   reverse the sort order
+  /                   type a filter, keeping the allocations with a frame containing it;
+                      Enter keeps it, Esc clears it
+  ?                   show or hide this help
+  q, Ctrl-C           quit`
+
+const tuiKeys = "Up/Down move  PgUp/PgDn page  s sort  r reverse  / filter  ? help  q quit"
+
+// tuiState is what -tui shows of the program points: the ones passing the
+// filter, sorted by the sort key, with the selected one and the part of the
+// list which fits on the screen.
+type tuiState struct {
+	report *Report
+	opts   *options
+
+	// The program points selected by the flags, in their original order.
+	all []int
+
+	filter    string
+	sortBy    string
+	ascending bool
+
+	// The sort keys which can be used with the report, in the order they
+	// are cycled through, after the file order.
+	sortNames []string
+
+	// The program points shown, the ones of all passing the filter, in
+	// the sort order.
+	shown []int
+
+	// The index in shown of the selected program point and of the first
+	// one listed.
+	sel, top int
+
+	// The number of program points listed by the last draw.
+	listRows int
+
+	editing bool
+	help    bool
+}
+
+// runTUI lets the user browse the program points pps of report in the
+// terminal, until q is pressed.
+func runTUI(report *Report, pps []int, opts *options) (err error) {
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := t.restore(); err == nil {
+			err = rerr
+		}
+	}()
+
+	// Use the alternate screen, which is given back as it was on exit.
+	fmt.Fprint(t, "\033[?1049h\033[?25l")
+	defer fmt.Fprint(t, "\033[?25h\033[?1049l")
+
+	s := newTUIState(report, pps, opts)
+	return s.run(t, t.size)
+}
+
+func newTUIState(report *Report, pps []int, opts *options) *tuiState {
+	s := &tuiState{
+		report:    report,
+		opts:      opts,
+		all:       pps,
+		sortBy:    opts.sortBy,
+		ascending: opts.sortAsc,
+	}
+	for _, name := range strings.Split(sortKeyNames(), ", ") {
+		k := sortKeys[name]
+		if k.needsAccesses && !report.BlockAccessesRecorded || k.needsLifetimes && !report.BlockLifetimesRecorded {
+			continue
+		}
+		s.sortNames = append(s.sortNames, name)
+	}
+	s.update()
+	return s
+}
+
+// run draws the screen on rw, of the size returned by size, and handles the
+// keys read from rw until q is pressed or rw ends.
+func (s *tuiState) run(rw io.ReadWriter, size func() (int, int)) error {
+	buf := make([]byte, 64)
+	for {
+		rows, cols := size()
+		if _, err := rw.Write(s.draw(rows, cols)); err != nil {
+			return err
+		}
+
+		n, err := rw.Read(buf)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			if s.handleKey(key) {
+				return nil
+			}
+		}
+	}
+}
+
+// parseKeys splits the bytes read from the terminal in the keys typed, named
+// like "up" or "enter" for the special ones and by their character otherwise.
+func parseKeys(b []byte) []string {
+	sequences := []struct {
+		seq, key string
+	}{
+		{"\033[A", "up"}, {"\033OA", "up"},
+		{"\033[B", "down"}, {"\033OB", "down"},
+		{"\033[5~", "pgup"}, {"\033[6~", "pgdn"},
+		{"\033[H", "home"}, {"\033[1~", "home"}, {"\033OH", "home"},
+		{"\033[F", "end"}, {"\033[4~", "end"}, {"\033OF", "end"},
+	}
+
+	var keys []string
+	for len(b) > 0 {
+		if b[0] == '\033' {
+			key := ""
+			for _, s := range sequences {
+				if bytes.HasPrefix(b, []byte(s.seq)) {
+					key = s.key
+					b = b[len(s.seq):]
+					break
+				}
+			}
+			switch {
+			case key != "":
+				keys = append(keys, key)
+			case len(b) == 1 || b[1] != '[' && b[1] != 'O':
+				// Esc alone, maybe read with the key typed after it.
+				keys = append(keys, "esc")
+				b = b[1:]
+			default:
+				// An unknown sequence, skipped up to its final byte.
+				n := 1
+				for n < len(b) && (n < 2 || b[n] < 0x40 || b[n] > 0x7e) {
+					n++
+				}
+				b = b[min(n+1, len(b)):]
+			}
+			continue
+		}
+
+		switch b[0] {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x7f, '\b':
+			keys = append(keys, "backspace")
+		case 3:
+			keys = append(keys, "ctrl-c")
+		default:
+			if r, n := utf8.DecodeRune(b); r >= ' ' {
+				keys = append(keys, string(b[:n]))
+				b = b[n:]
+				continue
+			}
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// handleKey updates the state for key, returning true if it quits.
+func (s *tuiState) handleKey(key string) bool {
+	if s.editing {
+		switch key {
+		case "ctrl-c":
+			return true
+		case "enter":
+			s.editing = false
+		case "esc":
+			s.editing = false
+			s.filter = ""
+			s.update()
+		case "backspace":
+			if s.filter != "" {
+				_, n := utf8.DecodeLastRuneInString(s.filter)
+				s.filter = s.filter[:len(s.filter)-n]
+				s.update()
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				s.filter += key
+				s.update()
+			}
+		}
+		return false
+	}
+
+	page := max(s.listRows, 1)
+	switch key {
+	case "q", "ctrl-c":
+		return true
+	case "?":
+		s.help = !s.help
+	case "esc":
+		s.help = false
+	case "up", "k":
+		s.sel--
+	case "down", "j":
+		s.sel++
+	case "pgup":
+		s.sel -= page
+	case "pgdn":
+		s.sel += page
+	case "home", "g":
+		s.sel = 0
+	case "end", "G":
+		s.sel = len(s.shown) - 1
+	case "/":
+		s.editing = true
+	case "s":
+		s.nextSortKey()
+	case "r":
+		s.ascending = !s.ascending
+		s.update()
+	}
+	s.sel = max(min(s.sel, len(s.shown)-1), 0)
+	return false
+}
+
+// nextSortKey sorts the program points by the key after the current one, or
+// in their file order after the last key.
+func (s *tuiState) nextSortKey() {
+	n := slices.Index(s.sortNames, s.sortBy)
+	if n+1 < len(s.sortNames) {
+		s.sortBy = s.sortNames[n+1]
+		s.ascending = sortKeys[s.sortBy].ascending
+	} else {
+		s.sortBy, s.ascending = "", false
+	}
+	s.update()
+}
+
+// update selects and sorts the program points shown, selecting the first
+// one.
+func (s *tuiState) update() {
+	s.shown = slices.DeleteFunc(slices.Clone(s.all), func(i int) bool {
+		return s.filter != "" && !s.report.ProgramPointHasFrame(i, s.filter)
+	})
+	if k, ok := sortKeys[s.sortBy]; ok {
+		sortProgramPoints(s.report, s.shown, k, s.ascending)
+	} else if s.ascending {
+		slices.Reverse(s.shown)
+	}
+	s.sel, s.top = 0, 0
+}
+
+// draw returns what is written to a terminal of the given size to show the
+// state: a title line, the list of the program points, the details of the
+// selected one, or the help, and a line with the keys or the filter typed.
+func (s *tuiState) draw(rows, cols int) []byte {
+	rows, cols = max(rows, 6), max(cols, 20)
+	r := s.report
+
+	s.listRows = (rows - 3) / 2
+	if s.sel < s.top {
+		s.top = s.sel
+	} else if s.sel >= s.top+s.listRows {
+		s.top = s.sel - s.listRows + 1
+	}
+
+	lines := make([]string, 0, rows)
+	var order string
+	switch {
+	case s.sortBy == "" && s.ascending:
+		order = "reversed file order"
+	case s.sortBy == "":
+		order = "file order"
+	case s.ascending:
+		order = "sorted by " + s.sortBy + " ascending"
+	default:
+		order = "sorted by " + s.sortBy + " descending"
+	}
+	title := fmt.Sprintf("%d of %d allocations, %s", len(s.shown), len(s.all), order)
+	if s.filter != "" {
+		title += fmt.Sprintf(", matching %q", s.filter)
+	}
+	lines = append(lines, reverseVideo(fitLine(title, cols)))
+
+	// The columns are as wide as their widest value of all the program
+	// points shown, so they don't move while scrolling.
+	nw, bw, kw := len(strconv.Itoa(len(s.shown)))+1, 0, 0
+	for _, i := range s.shown {
+		bw = max(bw, len(s.opts.bytes(r.ProgramPoints[i].TotalBytes)))
+		kw = max(kw, len(s.opts.count(r.ProgramPoints[i].TotalBlocks)))
+	}
+	for n := s.top; n < s.top+s.listRows; n++ {
+		if n >= len(s.shown) {
+			lines = append(lines, "")
+			continue
+		}
+		pp := r.ProgramPoints[s.shown[n]]
+		leaf := ""
+		if frames := s.opts.visibleFrames(r, pp); len(frames) != 0 {
+			leaf = escapeControl(s.opts.displayFrame(r.GetFrame(frames[0])))
+		}
+		line := fitLine(fmt.Sprintf(
+			"%*s  %*s %s  %*s %s  %s",
+			nw, "#"+strconv.Itoa(n+1), bw, s.opts.bytes(pp.TotalBytes), r.BytesLabel(),
+			kw, s.opts.count(pp.TotalBlocks), r.BlocksLabel(), leaf,
+		), cols)
+		if n == s.sel {
+			line = reverseVideo(line)
+		}
+		lines = append(lines, line)
+	}
+
+	detailRows := rows - 3 - s.listRows
+	var details []string
+	switch {
+	case s.help:
+		lines = append(lines, reverseVideo(fitLine("Help", cols)))
+		details = strings.Split(tuiHelp, "\n")
+	case len(s.shown) == 0:
+		lines = append(lines, reverseVideo(fitLine("No allocation", cols)))
+	default:
+		lines = append(lines, reverseVideo(fitLine(fmt.Sprintf("Allocation #%d", s.sel+1), cols)))
+		details = s.details(s.shown[s.sel])
+	}
+	if len(details) > detailRows {
+		more := len(details) - detailRows + 1
+		details = append(details[:detailRows-1], fmt.Sprintf("... %d more lines", more))
+	}
+	for n := 0; n < detailRows; n++ {
+		line := ""
+		if n < len(details) {
+			line = fitLine(details[n], cols)
+		}
+		lines = append(lines, line)
+	}
+
+	status := tuiKeys
+	if s.editing {
+		status = "/" + escapeControl(s.filter) + "_  (Enter keeps the filter, Esc clears it)"
+	}
+	lines = append(lines, fitLine(status, cols))
+
+	var b bytes.Buffer
+	b.WriteString("\033[H")
+	for n, line := range lines {
+		if n > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\033[K")
+	}
+	b.WriteString("\033[J")
+	return b.Bytes()
+}
+
+// details returns the lines showing the counts and the whole stack of the
+// program point i.
+func (s *tuiState) details(i int) []string {
+	r := s.report
+	pp := r.ProgramPoints[i]
+
+	lines := []string{fmt.Sprintf(
+		"%s %s in %s %s",
+		s.opts.bytes(pp.TotalBytes), r.BytesLabel(), s.opts.count(pp.TotalBlocks), r.BlocksLabel(),
+	)}
+	if r.BlockAccessesRecorded {
+		lines = append(lines, fmt.Sprintf("%d reads, %d writes", pp.ReadsOfBlocks, pp.WritesOfBlocks))
+	}
+	if r.BlockLifetimesRecorded {
+		lines = append(lines, fmt.Sprintf("%s %s at t-end", s.opts.bytes(pp.BytesAtTend), r.BytesLabel()))
+	}
+	for _, frame := range s.opts.displayStack(s.opts.visibleStack(r, pp)) {
+		lines = append(lines, "  "+escapeControl(frame))
+	}
+	return lines
+}
+
+// fitLine pads or truncates line to cols characters.
+func fitLine(line string, cols int) string {
+	line = truncateFrame(line, cols)
+	return line + strings.Repeat(" ", cols-utf8.RuneCountInString(line))
+}
+
+// reverseVideo returns line to be shown with the colors of the terminal
+// swapped.
+func reverseVideo(line string) string {
+	return "\033[7m" + line + "\033[0m"
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// framesTable is the frame table of a DHAT file. Unlike a []string, invalid
+// UTF-8 in its entries is kept as is when decoded, instead of being replaced
+// with U+FFFD, so that -sanitize-utf8 can show the original bytes.
+type framesTable []string
+
+func (t *framesTable) UnmarshalJSON(data []byte) error {
+	if utf8.Valid(data) {
+		return json.Unmarshal(data, (*[]string)(t))
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*t = make(framesTable, len(raw))
+	for n, entry := range raw {
+		s, err := decodeFrame(entry)
+		if err != nil {
+			return fmt.Errorf("ftbl[%d]: %w", n, err)
+		}
+		(*t)[n] = s
+	}
+	return nil
+}
+
+// decodeFrame decodes one entry of the frame table, keeping invalid UTF-8.
+func decodeFrame(data []byte) (string, error) {
+	if utf8.Valid(data) {
+		var s string
+		err := json.Unmarshal(data, &s)
+		return s, err
+	}
+	return unquoteRaw(data)
+}
+
+// unquoteRaw decodes the JSON string s, copying the bytes which aren't part of
+// an escape sequence unchanged, even if they aren't valid UTF-8.
+func unquoteRaw(s []byte) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("expected string, got %s", s)
+	}
+	s = s[1 : len(s)-1]
+
+	var b strings.Builder
+	for len(s) > 0 {
+		if s[0] != '\\' {
+			b.WriteByte(s[0])
+			s = s[1:]
+			continue
+		}
+		if len(s) < 2 {
+			return "", fmt.Errorf("unterminated escape sequence")
+		}
+		switch s[1] {
+		case '"', '\\', '/':
+			b.WriteByte(s[1])
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			r, n, err := unquoteRune(s)
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+			s = s[n:]
+			continue
+		default:
+			return "", fmt.Errorf("invalid escape sequence \\%c", s[1])
+		}
+		s = s[2:]
+	}
+	return b.String(), nil
+}
+
+// unquoteRune decodes the \uXXXX escape sequence at the start of s, together
+// with the one following it if they form a surrogate pair, and returns the
+// rune and the number of bytes used.
+func unquoteRune(s []byte) (rune, int, error) {
+	r, err := hex4(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	if utf16.IsSurrogate(r) {
+		if low, err := hex4(s[6:]); err == nil {
+			if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+				return pair, 12, nil
+			}
+		}
+		return utf8.RuneError, 6, nil
+	}
+	return r, 6, nil
+}
+
+func hex4(s []byte) (rune, error) {
+	if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+		return 0, fmt.Errorf("invalid \\u escape sequence")
+	}
+	n, err := strconv.ParseUint(string(s[2:6]), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid \\u escape sequence")
+	}
+	return rune(n), nil
+}
+
+// sanitizeUTF8 returns s with its invalid UTF-8 bytes replaced according to
+// mode: with U+FFFD for replace and with \xNN for hex.
+func sanitizeUTF8(s, mode string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	switch mode {
+	case "replace":
+		return strings.ToValidUTF8(s, "�")
+	case "hex":
+		var b strings.Builder
+		for len(s) > 0 {
+			r, size := utf8.DecodeRuneInString(s)
+			if r == utf8.RuneError && size == 1 {
+				fmt.Fprintf(&b, "\\x%02x", s[0])
+			} else {
+				b.WriteString(s[:size])
+			}
+			s = s[size:]
+		}
+		return b.String()
+	}
+	return s
+}
+
+// controlEscapes are the escapes of the control characters which have a
+// short one.
+var controlEscapes = map[rune]string{'\t': `\t`, '\n': `\n`, '\r': `\r`}
+
+// escapeControl returns s with its non-printable characters escaped, as \t,
+// \n, \r, \xNN for the other ASCII ones and \uNNNN beyond, for -escape-control.
+// Invalid UTF-8 is left to sanitizeUTF8.
+func escapeControl(s string) string {
+	if !strings.ContainsFunc(s, func(r rune) bool { return r != utf8.RuneError && !unicode.IsPrint(r) }) {
+		return s
+	}
+	var b strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == utf8.RuneError || unicode.IsPrint(r):
+			b.WriteString(s[:size])
+		case controlEscapes[r] != "":
+			b.WriteString(controlEscapes[r])
+		case r < utf8.RuneSelf:
+			fmt.Fprintf(&b, "\\x%02x", r)
+		default:
+			fmt.Fprintf(&b, "\\u%04x", r)
+		}
+		s = s[size:]
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often the DHAT file is checked for changes.
+const watchInterval = 500 * time.Millisecond
+
+// watch prints the report of the given DHAT file every time the file is
+// modified, until interrupted.
+func watch(file string, opts *options) error {
+	if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+		return fmt.Errorf("-watch needs a local DHAT file")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	var last time.Time
+	missing := false
+	for {
+		fi, err := os.Stat(file)
+		switch {
+		case err == nil:
+			missing = false
+		case errors.Is(err, fs.ErrNotExist) && !last.IsZero():
+			// Editors and valgrind replace the file by renaming a new one
+			// over it, so it can be briefly missing.
+			if !missing {
+				fmt.Fprintln(os.Stderr, "warning:", err)
+				missing = true
+			}
+		default:
+			return err
+		}
+
+		if err == nil && !fi.ModTime().Equal(last) {
+			last = fi.ModTime()
+			if !opts.html {
+				// Clear the screen and move the cursor to its top left.
+				fmt.Print("\033[H\033[2J")
+			}
+			// The file can be caught in the middle of being written, so
+			// errors are reported and the file is tried again on its next
+			// change.
+			if err := run(os.Stdout, file, opts); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}